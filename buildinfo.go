@@ -0,0 +1,69 @@
+package evs
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+var (
+	buildInfoMu  sync.RWMutex
+	buildVersion string
+	buildCommit  string
+	// The vcs fields are read from the build info once at startup, so they aren't guarded by buildInfoMu.
+	vcsRevision string
+	vcsTime     string
+	vcsModified bool
+)
+
+func init() {
+	info, ok := debug.ReadBuildInfo()
+	if ok {
+		buildVersion = info.Main.Version
+		setVCSInfo(info)
+	}
+}
+
+// SetBuildInfo sets the build version and commit that formatters include when they are configured to do so
+// (see [ShowBuildInfo]). By default the version is the main module version reported by [debug.ReadBuildInfo]
+// and the commit is empty. It doesn't affect the values reported by [VCSInfo].
+func SetBuildInfo(version, commit string) {
+	buildInfoMu.Lock()
+	defer buildInfoMu.Unlock()
+	buildVersion = version
+	buildCommit = commit
+}
+
+// setVCSInfo stores the vcs.revision, vcs.time, and vcs.modified settings of info, if it has a revision.
+func setVCSInfo(info *debug.BuildInfo) {
+	revision, committedAt, modified := "", "", false
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			revision = setting.Value
+		case "vcs.time":
+			committedAt = setting.Value
+		case "vcs.modified":
+			modified = setting.Value == "true"
+		}
+	}
+	if revision == "" {
+		return
+	}
+	vcsRevision, vcsTime, vcsModified = revision, committedAt, modified
+}
+
+// BuildInfo returns the build version and commit set via [SetBuildInfo].
+func BuildInfo() (version, commit string) {
+	buildInfoMu.RLock()
+	defer buildInfoMu.RUnlock()
+	return buildVersion, buildCommit
+}
+
+// VCSInfo returns the revision, commit time, and whether the working tree had local modifications, as the go
+// tool embeds them into binaries built from a version controlled checkout (the vcs.revision, vcs.time, and
+// vcs.modified settings of [debug.ReadBuildInfo]). They are read automatically at startup. The revision is
+// empty if that information isn't available (e.g. under go run, in tests, or when building with
+// -buildvcs=false).
+func VCSInfo() (revision, commitTime string, modified bool) {
+	return vcsRevision, vcsTime, vcsModified
+}
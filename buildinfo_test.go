@@ -0,0 +1,40 @@
+package evs
+
+import (
+	"runtime/debug"
+	"testing"
+)
+
+// setTestVCSInfo replaces the values reported by VCSInfo and restores them once the test is done.
+func setTestVCSInfo(t *testing.T, revision, commitTime string, modified bool) {
+	t.Helper()
+	originalRevision, originalTime, originalModified := VCSInfo()
+	t.Cleanup(func() {
+		vcsRevision, vcsTime, vcsModified = originalRevision, originalTime, originalModified
+	})
+	vcsRevision, vcsTime, vcsModified = revision, commitTime, modified
+}
+
+func TestSetVCSInfo(t *testing.T) {
+	setTestVCSInfo(t, "", "", false)
+	setVCSInfo(&debug.BuildInfo{
+		Settings: []debug.BuildSetting{
+			{Key: "vcs", Value: "git"},
+			{Key: "vcs.revision", Value: "abc123"},
+			{Key: "vcs.time", Value: "2023-09-01T12:00:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	})
+	revision, commitTime, modified := VCSInfo()
+	if revision != "abc123" || commitTime != "2023-09-01T12:00:00Z" || !modified {
+		t.Fatalf("unexpected vcs info %q %q %v", revision, commitTime, modified)
+	}
+}
+
+func TestSetVCSInfo_Missing(t *testing.T) {
+	setTestVCSInfo(t, "abc123", "", false)
+	setVCSInfo(&debug.BuildInfo{})
+	if revision, _, _ := VCSInfo(); revision != "abc123" {
+		t.Fatalf("expected the revision to be unchanged but got %v", revision)
+	}
+}
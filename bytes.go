@@ -0,0 +1,29 @@
+package evs
+
+import (
+	"math"
+	"strconv"
+)
+
+// Bytes is a byte count to be used as a structured field value (e.g. With("size", evs.Bytes(n))). The text
+// formatter shows it in a human-friendly form like "1.5MB", while the JSON formatter keeps the plain number.
+type Bytes int64
+
+// byteUnits are the units used by [Bytes.String], each 1000 times the previous one.
+var byteUnits = []string{"B", "KB", "MB", "GB", "TB", "PB", "EB"}
+
+// String implements the [fmt.Stringer] interface. It uses decimal units and rounds to one decimal place, e.g.
+// "512B", "1.5MB", or "3GB".
+func (b Bytes) String() string {
+	sign, n := "", math.Abs(float64(b))
+	if b < 0 {
+		sign = "-"
+	}
+	unit := 0
+	// Values that would round up to 1000 move on to the next unit, so there is never a "1000KB".
+	for n >= 999.95 && unit < len(byteUnits)-1 {
+		n /= 1000
+		unit++
+	}
+	return sign + strconv.FormatFloat(math.Round(n*10)/10, 'f', -1, 64) + byteUnits[unit]
+}
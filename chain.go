@@ -0,0 +1,280 @@
+package evs
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultChainSeparator is what [ChainString] puts between two links when no separator is given.
+const DefaultChainSeparator = " <- "
+
+// visitSet keeps track of the [Error]s seen while walking a chain. It only allocates once a chain holds more
+// errors than fit in the inline array.
+type visitSet struct {
+	inline [8]*Error
+	n      int
+	spill  map[*Error]bool
+	cyclic bool
+}
+
+// add records e and returns false if it has already been seen.
+func (v *visitSet) add(e *Error) bool {
+	for i := 0; i < v.n; i++ {
+		if v.inline[i] == e {
+			return false
+		}
+	}
+	if v.spill[e] {
+		return false
+	}
+	if v.n < len(v.inline) {
+		v.inline[v.n] = e
+		v.n++
+		return true
+	}
+	if v.spill == nil {
+		v.spill = map[*Error]bool{}
+	}
+	v.spill[e] = true
+	return true
+}
+
+// walk calls fn for every [Error] in the chain starting at err, from the outermost to the innermost. It follows
+// both Unwrap() error and Unwrap() []error, and stops as soon as fn returns false. If an [Error] shows up a
+// second time the chain is cyclic, and the walk stops there instead of looping forever.
+func walk(err error, fn func(e *Error) bool) bool {
+	visited := visitSet{}
+	return walkChain(err, fn, &visited)
+}
+
+func walkChain(err error, fn func(e *Error) bool, visited *visitSet) bool {
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			if !visited.add(e) {
+				visited.cyclic = true
+				return false
+			}
+			if !fn(e) {
+				return false
+			}
+		}
+		switch x := err.(type) {
+		case interface{ Unwrap() []error }:
+			for _, branch := range x.Unwrap() {
+				if !walkChain(branch, fn, visited) {
+					return false
+				}
+			}
+			return true
+		default:
+			err = errors.Unwrap(err)
+		}
+	}
+	return true
+}
+
+// IsCyclic reports whether the chain of err wraps back around to an [Error] it already contains. Such chains
+// are always the result of a bug, and most code that unwraps errors (including [errors.Is] and [errors.As]
+// when they find no match) will loop forever on them. Only cycles that pass through an [Error] are detected.
+func IsCyclic(err error) bool {
+	visited := visitSet{}
+	walkChain(err, func(e *Error) bool { return true }, &visited)
+	return visited.cyclic
+}
+
+// RootCause returns the innermost error in the chain of err by following Unwrap() error until there is nothing
+// left to unwrap. It stops at joined errors (which have several causes) and returns the joined error itself.
+// It also stops if the chain turns out to be cyclic. It returns nil if err is nil.
+func RootCause(err error) error {
+	visited := visitSet{}
+	for err != nil {
+		if e, ok := err.(*Error); ok && !visited.add(e) {
+			return err
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok || u.Unwrap() == nil {
+			return err
+		}
+		err = u.Unwrap()
+	}
+	return nil
+}
+
+// ChainString renders the chain of err on a single line as the primary message of each link, from the
+// outermost to the innermost, joined by sep (e.g. "outer msg <- middle msg <- root msg"). If sep is empty,
+// [DefaultChainSeparator] is used. The primary message of an [Error] is its first non-empty detail, and the
+// primary message of any other error is its own text without that of the error it wraps. Links without a
+// message of their own are skipped. The branches of a joined error are rendered in brackets, separated by
+// commas. It returns an empty string if err is nil.
+func ChainString(err error, sep string) string {
+	if sep == "" {
+		sep = DefaultChainSeparator
+	}
+	visited := visitSet{}
+	return strings.Join(chainMessages(err, sep, &visited), sep)
+}
+
+func chainMessages(err error, sep string, visited *visitSet) []string {
+	messages := []string{}
+	for err != nil {
+		if e, ok := err.(*Error); ok && !visited.add(e) {
+			return append(messages, cycleDetected)
+		}
+		if joined, ok := err.(interface{ Unwrap() []error }); ok {
+			branches := []string{}
+			for _, branch := range joined.Unwrap() {
+				branches = append(branches, strings.Join(chainMessages(branch, sep, visited), sep))
+			}
+			return append(messages, "["+strings.Join(branches, ", ")+"]")
+		}
+		if msg := primaryMessage(err); msg != "" {
+			messages = append(messages, msg)
+		}
+		err = errors.Unwrap(err)
+	}
+	return messages
+}
+
+// primaryMessage returns the message that belongs to err itself rather than to the error it wraps.
+func primaryMessage(err error) string {
+	if e, ok := err.(*Error); ok {
+		for _, detail := range e.Details {
+			if detail.Message != "" {
+				return detail.Message
+			}
+		}
+		return ""
+	}
+	msg := err.Error()
+	if inner := errors.Unwrap(err); inner != nil {
+		if trimmed, ok := strings.CutSuffix(msg, inner.Error()); ok {
+			msg = strings.TrimRight(trimmed, ": ")
+		}
+	}
+	return msg
+}
+
+// formattedString returns what err.Error() returns while [SetErrorStringMode] is [ErrorStringFormatted]: the full
+// output of the formatter of every [Error] in the chain. Formatters use it in place of Error(), so that their
+// output doesn't change with the mode. The text of any other wrapper is kept, and only the part of it that came
+// from the errors it wraps is formatted again.
+func formattedString(err error) string {
+	if e, ok := err.(*Error); ok {
+		return fmt.Sprintf("%+v", e)
+	}
+	msg := err.Error()
+	if ErrorStringMode(errorStringMode.Load()) == ErrorStringFormatted || !containsError(err) {
+		return msg
+	}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		// This is the layout of errors.Join, which is the only one that can be rebuilt.
+		branches, formatted := []string{}, []string{}
+		for _, branch := range joined.Unwrap() {
+			if branch != nil {
+				branches = append(branches, branch.Error())
+				formatted = append(formatted, formattedString(branch))
+			}
+		}
+		if msg == strings.Join(branches, "\n") {
+			return strings.Join(formatted, "\n")
+		}
+		return msg
+	}
+	if inner := errors.Unwrap(err); inner != nil {
+		if prefix, ok := strings.CutSuffix(msg, inner.Error()); ok {
+			return prefix + formattedString(inner)
+		}
+	}
+	return msg
+}
+
+// message returns the summary of err if it is an [Error] and its Error() string otherwise.
+func message(err error) string {
+	if e, ok := err.(*Error); ok {
+		return e.Summary()
+	}
+	return err.Error()
+}
+
+// chainContains reports whether target is somewhere in the chain of err.
+func chainContains(err error, target *Error) bool {
+	found := false
+	walk(err, func(e *Error) bool {
+		found = e == target
+		return !found
+	})
+	return found
+}
+
+// TrimChain returns a copy of the chain of err that keeps at most maxDepth of its outermost links plus the root
+// cause, with a "(N links dropped)" detail on the last kept link in place of everything in between. Unlike the
+// limits of the formatters, the result is a real error value, so it can be logged or passed on as is. The root
+// cause itself is kept, so its message and [errors.Is] on it still work. The kept [Error]s are shallow copies
+// that don't affect err. Any other kept wrapper (e.g. from fmt.Errorf) becomes an [Error] holding just its own
+// message, since its text can't be separated from that of the errors it wraps. A joined error counts as the
+// root cause and is kept whole. A maxDepth below 1 is treated as 1. If nothing needs to be dropped, the result
+// is the same as [Coerce]. It returns nil if err is nil.
+func TrimChain(err error, maxDepth int) *Error {
+	if err == nil {
+		return nil
+	}
+	maxDepth = max(maxDepth, 1)
+	links := chainLinks(err)
+	if len(links) <= maxDepth+1 {
+		return coerce(initialSkip, err)
+	}
+	root := links[len(links)-1]
+	kept := make([]*Error, 0, maxDepth)
+	for _, link := range links[:maxDepth] {
+		kept = append(kept, trimmedLink(link))
+	}
+	for i := 0; i < len(kept)-1; i++ {
+		kept[i].Wraps = kept[i+1]
+	}
+	last := kept[len(kept)-1]
+	last.Wraps = root
+	dropped := len(links) - maxDepth - 1
+	last.Details = append(last.Details, newDetail("("+strconv.Itoa(dropped)+" links dropped)", LevelUnset))
+	return kept[0]
+}
+
+// chainLinks returns the links of the chain of err from the outermost to the innermost, stopping at a joined
+// error and before an [Error] that was already seen.
+func chainLinks(err error) []error {
+	links := []error{}
+	visited := visitSet{}
+	for err != nil {
+		if e, ok := err.(*Error); ok && !visited.add(e) {
+			break
+		}
+		links = append(links, err)
+		if _, ok := err.(interface{ Unwrap() []error }); ok {
+			break
+		}
+		err = errors.Unwrap(err)
+	}
+	return links
+}
+
+// trimmedLink returns a copy of link for [TrimChain] whose Wraps can be set without affecting the original.
+func trimmedLink(link error) *Error {
+	e, ok := link.(*Error)
+	if !ok {
+		out := &Error{f: GetFormatterFunc()}
+		if msg := primaryMessage(link); msg != "" {
+			out.Details = []Detail{newDetail(msg, LevelUnset)}
+		}
+		return out
+	}
+	out := *e
+	out.Details = slices.Clone(e.Details)
+	if e.isCache != nil {
+		// Cached results were for the original chain.
+		out.isCache = &sync.Map{}
+	}
+	return &out
+}
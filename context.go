@@ -0,0 +1,58 @@
+package evs
+
+import (
+	"context"
+	"sync"
+)
+
+var (
+	contextExtractorMu sync.RWMutex
+	contextExtractor   func(ctx context.Context) map[string]any
+)
+
+// SetContextExtractor sets the function that [NewCtx] and [WrapCtx] use to pull structured fields (such as trace
+// IDs, baggage, or the tenant) out of a context. The extractor runs once, when the error is constructed, so
+// later changes to the context aren't reflected in the error. Passing nil removes the extractor.
+func SetContextExtractor(fn func(ctx context.Context) map[string]any) {
+	contextExtractorMu.Lock()
+	defer contextExtractorMu.Unlock()
+	contextExtractor = fn
+}
+
+// NewCtx is the same as [New], but also adds the fields returned by the extractor set via
+// [SetContextExtractor] for ctx.
+func NewCtx(ctx context.Context, msg string) *Record {
+	err := newError(initialSkip)
+	err.Details = append(err.Details, callerDetail(initialSkip, msg, LevelUnset))
+	extractContext(ctx, err)
+	return newRecord(err)
+}
+
+// WrapCtx is the same as [From], but also adds the fields returned by the extractor set via
+// [SetContextExtractor] for ctx. Fields that the error already has are kept rather than overridden by the
+// context, since they were set closer to where the failure happened.
+func WrapCtx(ctx context.Context, err error) *Record {
+	if err == nil {
+		return newRecord(nil)
+	}
+	newErr := from(initialSkip, err)
+	extractContext(ctx, newErr)
+	return newRecord(newErr)
+}
+
+func extractContext(ctx context.Context, err *Error) {
+	contextExtractorMu.RLock()
+	fn := contextExtractor
+	contextExtractorMu.RUnlock()
+	if fn == nil || ctx == nil {
+		return
+	}
+	for key, value := range fn(ctx) {
+		if err.Fields == nil {
+			err.Fields = map[string]any{}
+		}
+		if _, ok := err.Fields[key]; !ok {
+			err.Fields[key] = value
+		}
+	}
+}
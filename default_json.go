@@ -0,0 +1,8 @@
+//go:build evs_json
+
+package evs
+
+// Building with the evs_json tag makes the [JSONFormatter] the default. It takes precedence over evs_text.
+func init() {
+	GetFormatterFunc = JSONFormatter
+}
@@ -0,0 +1,11 @@
+//go:build evs_json
+
+package evs
+
+import "testing"
+
+func TestDefaultFormatter_JSON(t *testing.T) {
+	if _, ok := GetFormatterFunc().(jsonFormatter); !ok {
+		t.Fatalf("expected the JSON formatter to be the default but got %T", GetFormatterFunc())
+	}
+}
@@ -0,0 +1,9 @@
+//go:build evs_text && !evs_json
+
+package evs
+
+// Building with the evs_text tag makes the text formatter the default. Since that already is the default, the tag
+// only matters to pin it down explicitly (e.g. next to build scripts that pass evs_json elsewhere).
+func init() {
+	GetFormatterFunc = TextFormatter
+}
@@ -0,0 +1,11 @@
+//go:build evs_text && !evs_json
+
+package evs
+
+import "testing"
+
+func TestDefaultFormatter_Text(t *testing.T) {
+	if _, ok := GetFormatterFunc().(textFormatter); !ok {
+		t.Fatalf("expected the text formatter to be the default but got %T", GetFormatterFunc())
+	}
+}
@@ -0,0 +1,126 @@
+package evs
+
+import (
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// annotateCaller is set via [SetAnnotateCaller].
+	annotateCaller atomic.Bool
+	// detailTimestamps is set via [SetDetailTimestamps].
+	detailTimestamps atomic.Bool
+)
+
+// SetAnnotateCaller controls whether the messages added via [New], [Newf], [NewCtx], [Record.Msg],
+// [Record.Msgf], and [Record.MsgAt] record the function they were added from (as [Detail.Location]), so that
+// the text formatter prefixes them with its short name, e.g. "dialHost: timeout". This helps with triage
+// without reading the whole stack. It costs one extra runtime lookup per message. By default it is off.
+func SetAnnotateCaller(on bool) {
+	annotateCaller.Store(on)
+}
+
+// Level describes how important a piece of information is. The zero value is [LevelUnset].
+type Level int
+
+const (
+	LevelUnset Level = iota
+	LevelDebug
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelFatal
+)
+
+// String implements the [fmt.Stringer] interface.
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	case LevelFatal:
+		return "FATAL"
+	default:
+		return "UNSET"
+	}
+}
+
+// Detail is a single message that has been attached to an [Error].
+type Detail struct {
+	Message string
+	// Level is the importance of the detail. Details added via [Record.Msg] have no level set, which means
+	// formatters always print them.
+	Level Level
+	// Location is where the detail was recorded. It is only captured when [Record.Var] is used or
+	// [SetAnnotateCaller] is on.
+	Location Frame
+	// Vars are caller-provided values tied to the location of the detail.
+	Vars []Var
+	// Time is when the detail was added. It is only set when [SetDetailTimestamps] is on.
+	Time time.Time
+	// annotated is set if Location was recorded because [SetAnnotateCaller] was on, rather than by [Record.Var].
+	annotated bool
+}
+
+// SetDetailTimestamps controls whether each detail records the time it was added (as [Detail.Time]). See [Age]
+// and [DetailOffsets]. It is safe to call concurrently with creating errors. By default it is off.
+func SetDetailTimestamps(on bool) {
+	detailTimestamps.Store(on)
+}
+
+// newDetail creates a detail with the given message and level, stamping it with the current time if
+// [SetDetailTimestamps] is on.
+func newDetail(msg string, level Level) Detail {
+	detail := Detail{Message: msg, Level: level}
+	if detailTimestamps.Load() {
+		detail.Time = now()
+	}
+	return detail
+}
+
+// callerDetail is the same as newDetail, but also records the location of the caller skip frames up if
+// [SetAnnotateCaller] is on.
+func callerDetail(skip int, msg string, level Level) Detail {
+	detail := newDetail(msg, level)
+	if annotateCaller.Load() {
+		detail.Location = CurrentFrame(skip + 1)
+		detail.annotated = true
+	}
+	return detail
+}
+
+// trimImportPath trims the import path from a fully-qualified function name but keeps the package name, e.g.
+// "github.com/a/b.(*T).Dial" becomes "b.(*T).Dial".
+func trimImportPath(function string) string {
+	// Type parameters can contain import paths too, so only look for the last slash before them.
+	end := strings.IndexByte(function, '[')
+	if end < 0 {
+		end = len(function)
+	}
+	if i := strings.LastIndexByte(function[:end], '/'); i >= 0 {
+		return function[i+1:]
+	}
+	return function
+}
+
+// shortFunctionName trims the whole package from a fully-qualified function name, e.g. "github.com/a/b.(*T).Dial"
+// becomes "(*T).Dial".
+func shortFunctionName(function string) string {
+	function = trimImportPath(function)
+	if i := strings.IndexByte(function, '.'); i >= 0 {
+		function = function[i+1:]
+	}
+	return function
+}
+
+// Var is a named value attached to a [Detail] via [Record.Var].
+type Var struct {
+	Key   string
+	Value any
+}
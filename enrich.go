@@ -0,0 +1,53 @@
+package evs
+
+import (
+	"slices"
+	"sync"
+)
+
+var (
+	enrichersMu sync.RWMutex
+	// enrichers holds pointers so that the unregister function of each registration can find its own entry,
+	// since functions can't be compared.
+	enrichers []*CauseEnricher
+)
+
+// CauseEnricher inspects a wrapped error and returns any metadata that should be stored in the
+// [Error.Fields]. It should return nil if it doesn't recognize the error.
+type CauseEnricher func(error) map[string]any
+
+// RegisterEnricher adds a [CauseEnricher] which will be invoked on the wrapped error every time a new [Error]
+// is created from an existing error (e.g. via [From]) or given one to wrap via [Record.Set]. Enrichers run in the order they were registered, and
+// later enrichers override fields set by earlier ones. This is typically called once from an init function.
+// The returned function removes the enricher again (e.g. at the end of a test); calling it more than once has
+// no further effect.
+func RegisterEnricher(fn CauseEnricher) (unregister func()) {
+	enrichersMu.Lock()
+	defer enrichersMu.Unlock()
+	entry := &fn
+	enrichers = append(enrichers, entry)
+	return func() {
+		enrichersMu.Lock()
+		defer enrichersMu.Unlock()
+		enrichers = slices.DeleteFunc(enrichers, func(e *CauseEnricher) bool { return e == entry })
+	}
+}
+
+func enrich(err *Error) {
+	if err.Wraps == nil {
+		return
+	}
+	// The enrichers run without holding the lock, so that they can create errors or (un)register enrichers
+	// themselves.
+	enrichersMu.RLock()
+	registered := slices.Clone(enrichers)
+	enrichersMu.RUnlock()
+	for _, fn := range registered {
+		for key, value := range (*fn)(err.Wraps) {
+			if err.Fields == nil {
+				err.Fields = map[string]any{}
+			}
+			err.Fields[key] = value
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package evs
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+)
+
+// Equal reports whether err and other describe the same failure: the same kinds, details, tags, fields, and
+// wrapped errors, with stacks made up of the same functions and files. Line numbers, detail timestamps, and
+// sentinels are ignored, so errors created by the same code compare equal even after that code moves around.
+// Wrapped errors that aren't an [Error] are compared by their type and message. Since go-cmp calls Equal methods
+// automatically, this also makes cmp.Diff on values holding errors stable.
+func (err *Error) Equal(other *Error) bool {
+	if err == nil || other == nil {
+		return err == other
+	}
+	va, vb := visitSet{}, visitSet{}
+	return equalChain(err, other, &va, &vb)
+}
+
+// equalChain compares the chains of a and b link by link. Both chains are cyclic at the same point if an
+// [Error] is revisited in each of them at once.
+func equalChain(a, b error, va, vb *visitSet) bool {
+	for {
+		if a == nil || b == nil {
+			return a == nil && b == nil
+		}
+		ea, okA := a.(*Error)
+		eb, okB := b.(*Error)
+		if okA != okB {
+			return false
+		}
+		if okA {
+			if ea == eb {
+				return true
+			}
+			newA, newB := va.add(ea), vb.add(eb)
+			if !newA || !newB {
+				return newA == newB
+			}
+			if !ea.sameLink(eb) {
+				return false
+			}
+			a, b = ea.Wraps, eb.Wraps
+			continue
+		}
+		if reflect.TypeOf(a) != reflect.TypeOf(b) {
+			return false
+		}
+		// The message of a foreign wrapper includes the messages (and so the stacks) of any Error it wraps, so
+		// only compare it directly if there are none.
+		if !containsError(a) && !containsError(b) {
+			return a.Error() == b.Error()
+		}
+		if ja, ok := a.(interface{ Unwrap() []error }); ok {
+			branchesA, branchesB := ja.Unwrap(), b.(interface{ Unwrap() []error }).Unwrap()
+			if len(branchesA) != len(branchesB) {
+				return false
+			}
+			for i := range branchesA {
+				if !equalChain(branchesA[i], branchesB[i], va, vb) {
+					return false
+				}
+			}
+			return true
+		}
+		a, b = errors.Unwrap(a), errors.Unwrap(b)
+	}
+}
+
+// sameLink compares everything about err and other except for the errors they wrap.
+func (err *Error) sameLink(other *Error) bool {
+	if err.Kind != other.Kind || err.Level != other.Level || err.Status != other.Status || err.Hint != other.Hint || err.Op != other.Op || err.RetryAfter != other.RetryAfter || !slices.Equal(err.Tags, other.Tags) {
+		return false
+	}
+	if len(err.Fields) != len(other.Fields) || (len(err.Fields) > 0 && !reflect.DeepEqual(err.Fields, other.Fields)) {
+		return false
+	}
+	if !slices.EqualFunc(err.Details, other.Details, sameDetail) {
+		return false
+	}
+	return slices.EqualFunc(err.Stack.Frames, other.Stack.Frames, func(a, b Frame) bool {
+		return a.Function == b.Function && a.File == b.File
+	})
+}
+
+// sameDetail compares two details, ignoring their timestamps and the line numbers of their locations.
+func sameDetail(a, b Detail) bool {
+	if a.Message != b.Message || a.Level != b.Level {
+		return false
+	}
+	if a.Location.Function != b.Location.Function || a.Location.File != b.Location.File {
+		return false
+	}
+	return slices.EqualFunc(a.Vars, b.Vars, func(a, b Var) bool {
+		return a.Key == b.Key && reflect.DeepEqual(a.Value, b.Value)
+	})
+}
+
+// containsError reports whether there is an [Error] anywhere in the chain of err.
+func containsError(err error) bool {
+	found := false
+	walk(err, func(e *Error) bool {
+		found = true
+		return false
+	})
+	return found
+}
@@ -3,6 +3,11 @@ package evs
 import (
 	"errors"
 	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const (
@@ -11,17 +16,29 @@ const (
 	KindUnknown = ""
 	KindValue   = "Value"
 	initialSkip = 1
+	// maxWrapDepthDetail is the message added to errors that hit the limit of [SetMaxWrapDepth].
+	maxWrapDepthDetail = "(max wrap depth reached)"
+	// defaultMaxWrapDepth is the limit of [SetMaxWrapDepth] until it is changed.
+	defaultMaxWrapDepth = 1000
 )
 
 var (
 	// IncludeStack is used to determine whether or not a stacktrace should be captured with
 	// new errors. By default it is set to true.
 	IncludeStack = true
-	// InspectFull controls how [From] operates. By default, the full error stack will be inspected
-	// via [errors.As]. If any [evs.Error] exists within the stack, that error is extracted and returned.
+	// InspectFull controls how [From] operates. By default, the full error stack will be inspected.
+	// If any [evs.Error] exists within the stack, that error is extracted and returned. Joined errors
+	// (e.g. from [errors.Join]) are not searched, since extracting one branch would drop the others.
 	// You can turn this behavior off, by setting InspectFull to false. This will then only check the
 	// error itself (without calling unwrap).
 	InspectFull = true
+	// captureElapsed is set via [SetCaptureElapsed].
+	captureElapsed atomic.Bool
+	// now returns the current time. It is only replaced by the tests of this package, which need a fixed clock,
+	// and can't be changed from outside of it.
+	now = time.Now
+	// startTime is when the package was initialized, which [Error.Elapsed] is measured from.
+	startTime = now()
 	// compiler type enforcement
 	_ = error(&Error{})
 )
@@ -30,11 +47,34 @@ type Kind string
 
 // Error implements both the Error interface as well as Unwrap.
 type Error struct {
-	Wraps   error
-	Stack   Stack
-	Details []string
+	Wraps error
+	Stack Stack
+	// CapturedAt is the stack of the code that picked the error up after it crossed an async boundary, e.g. a
+	// channel between two goroutines. It is only set via [WithCapturedAt].
+	CapturedAt Stack
+	// Details used to be a []string holding only the messages. Use [Error.DetailMessages] where that form is
+	// still needed.
+	Details []Detail
 	Kind    Kind
-	f       Formatter
+	// Level is how severe the error is. Zero means it is not set, which [LevelOf] treats as [LevelError].
+	Level  Level
+	Fields map[string]any
+	Tags   []string
+	// Elapsed is the (monotonic) time between program start and the creation of the error. It is only set when
+	// [SetCaptureElapsed] is on.
+	Elapsed time.Duration
+	// Hint is short guidance on how to fix the problem, e.g. "check the DATABASE_URL env var". See [Hint].
+	Hint string
+	// Op is the name of the operation that failed, e.g. "db.Query". See [OpOf].
+	Op string
+	// Status is the HTTP status code that the error should be reported with. Zero means it is not set.
+	Status int
+	// RetryAfter is how long the caller should wait before retrying the operation. Zero means it is not set.
+	RetryAfter time.Duration
+	f          Formatter
+	sentinels  []error
+	// isCache holds the results of [Error.Is] for comparable targets. It is nil unless [Record.CacheIs] was used.
+	isCache *sync.Map
 }
 
 func newError(skip int) *Error {
@@ -42,17 +82,32 @@ func newError(skip int) *Error {
 	err := &Error{
 		f: GetFormatterFunc(),
 	}
-	if IncludeStack {
+	if IncludeStack && sampleStack() {
 		err.Stack = GetStack(skip)
 	}
+	if captureElapsed.Load() {
+		err.Elapsed = now().Sub(startTime)
+	}
 	return err
 }
 
+// SetCaptureElapsed controls whether new errors record how long the program had been running when they were
+// created (see [Error.Elapsed]). This is cheap to capture and makes bursts of errors easy to spot. It is safe to
+// call concurrently with creating errors. By default it is off.
+func SetCaptureElapsed(on bool) {
+	captureElapsed.Store(on)
+}
+
+// formatElapsed renders d as "t+hh:mm:ss".
+func formatElapsed(d time.Duration) string {
+	seconds := int64(d / time.Second)
+	return fmt.Sprintf("t+%02d:%02d:%02d", seconds/3600, seconds/60%60, seconds%60)
+}
+
 func from(skip int, wraps error) *Error {
 	skip++
 	if InspectFull {
-		check := &Error{}
-		if errors.As(wraps, &check) {
+		if check, ok := nearestLinear(wraps); ok {
 			return check
 		}
 	} else {
@@ -61,22 +116,255 @@ func from(skip int, wraps error) *Error {
 			return err
 		}
 	}
+	if outer, ok := nearestLinear(wraps); ok && wrapDepth(wraps) >= wrapDepthLimit() {
+		// outer may be shared by the caller, so the detail goes on a copy.
+		limited := trimmedLink(outer)
+		if len(limited.Details) == 0 || limited.Details[len(limited.Details)-1].Message != maxWrapDepthDetail {
+			limited.Details = append(limited.Details, newDetail(maxWrapDepthDetail, LevelUnset))
+		}
+		return limited
+	}
 	err := newError(skip)
 	err.Wraps = wraps
+	inheritFields(err)
+	enrich(err)
 	return err
 }
 
+// inheritFields copies the structured fields of every [Error] in the wrapped chain into err, so that the
+// outermost error carries the full context. When several errors set the same key, the outermost one wins, and
+// anything set on err afterwards (by an enricher or [Record.With]) overrides the inherited values.
+func inheritFields(err *Error) {
+	walk(err.Wraps, func(e *Error) bool {
+		for key, value := range e.Fields {
+			if err.Fields == nil {
+				err.Fields = map[string]any{}
+			}
+			if _, ok := err.Fields[key]; !ok {
+				err.Fields[key] = value
+			}
+		}
+		return true
+	})
+}
+
+// maxWrapDepth is set via [SetMaxWrapDepth]. Zero means the default is used.
+var maxWrapDepth atomic.Int64
+
+// SetMaxWrapDepth sets the maximum number of [Error]s that can be nested inside each other, which guards against
+// runaway wrapping (e.g. a retry loop that wraps the previous attempt's error every time). Once a chain is this
+// deep, [From] stops nesting and instead returns the outermost [Error] with a single "(max wrap depth reached)"
+// detail added, and [Record.Set] wraps a copy of the chain trimmed by [TrimChain], which keeps the root cause. A
+// value of zero or less restores the default of 1000, and values below 3 are raised to 3, since a trimmed chain
+// still needs its outermost link and its root cause. It is safe to call concurrently with creating errors.
+func SetMaxWrapDepth(n int) {
+	switch {
+	case n <= 0:
+		n = 0
+	case n < 3:
+		n = 3
+	}
+	maxWrapDepth.Store(int64(n))
+}
+
+// wrapDepthLimit returns the limit set via [SetMaxWrapDepth].
+func wrapDepthLimit() int {
+	if n := maxWrapDepth.Load(); n > 0 {
+		return int(n)
+	}
+	return defaultMaxWrapDepth
+}
+
+// wrapDepth counts the [Error]s in the chain of err that can be reached without going through a joined error.
+// It stops counting once it reaches the limit of [SetMaxWrapDepth].
+func wrapDepth(err error) int {
+	depth := 0
+	limit := wrapDepthLimit()
+	for err != nil && depth < limit {
+		if _, ok := err.(*Error); ok {
+			depth++
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			break
+		}
+		err = u.Unwrap()
+	}
+	return depth
+}
+
+// AsError returns the outermost [Error] in the chain of err, which is a shorter way of calling [errors.As] with
+// an *Error target. Like [From], it doesn't search inside joined errors (e.g. from [errors.Join]): the branches
+// of a join stay separate errors, so an [Error] that wraps a join is returned as is, while a join that isn't
+// wrapped in an [Error] gives false.
+func AsError(err error) (*Error, bool) {
+	return nearestLinear(err)
+}
+
+// nearestLinear returns the first [Error] in the chain of err that can be reached without going through a joined
+// error (one that implements Unwrap() []error). Extracting an [Error] from inside a joined error would drop
+// every other branch, which breaks [errors.Is] and [errors.As] for the errors in those branches.
+func nearestLinear(err error) (*Error, bool) {
+	for err != nil {
+		if e, ok := err.(*Error); ok {
+			return e, true
+		}
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok {
+			return nil, false
+		}
+		err = u.Unwrap()
+	}
+	return nil, false
+}
+
+// ErrorStringMode controls what [Error.Error] returns. See [SetErrorStringMode].
+type ErrorStringMode int32
+
+const (
+	// ErrorStringFormatted makes Error() return the full output of the formatter of the error, the same as
+	// formatting it with %+v. This is the default.
+	ErrorStringFormatted ErrorStringMode = iota
+	// MessageOnly makes Error() return just the message of the error itself (see [Error.Summary]).
+	MessageOnly
+	// WithCause makes Error() return the message of the error followed by that of its immediate cause, e.g.
+	// "loading user <- connection refused".
+	WithCause
+	// FullChain makes Error() return the message of every link of the chain, as [ChainString] does.
+	FullChain
+)
+
+// errorStringMode is set via [SetErrorStringMode].
+var errorStringMode atomic.Int32
+
+// SetErrorStringMode controls how verbose [Error.Error] is for every [Error], e.g. so that tests asserting on
+// it don't break whenever a stack or a wrapped message changes. The messages of the other modes are joined by
+// [DefaultChainSeparator]. Formatting an error with %v (or any other verb), [SingleLine], and the "wraps" of the
+// JSON formatters are unaffected and keep using the formatters of the chain, also for the [Error]s that plain
+// wrappers (e.g. from fmt.Errorf or errors.Join) hold. Only other wrappers whose text doesn't end with that of the
+// error they wrap keep whatever their Error() returns. By default the mode is [ErrorStringFormatted].
+func SetErrorStringMode(mode ErrorStringMode) {
+	errorStringMode.Store(int32(mode))
+}
+
 // Error implements the error interface.
 func (err *Error) Error() string {
+	switch ErrorStringMode(errorStringMode.Load()) {
+	case MessageOnly:
+		return err.Summary()
+	case WithCause:
+		visited := visitSet{}
+		messages := chainMessages(err, DefaultChainSeparator, &visited)
+		return strings.Join(messages[:min(len(messages), 2)], DefaultChainSeparator)
+	case FullChain:
+		return ChainString(err, "")
+	}
 	return fmt.Sprintf("%+v", err)
 }
 
+// Summary returns a short, single line description of the error that is suitable for end users. It returns
+// the first non-empty detail message if there is one, otherwise the message of the wrapped error, and
+// otherwise an empty string. Unlike [Error.Error], it never includes the stack or the rest of the details.
+func (err *Error) Summary() string {
+	for _, detail := range err.Details {
+		if detail.Message != "" {
+			return detail.Message
+		}
+	}
+	if err.Wraps != nil {
+		return err.Wraps.Error()
+	}
+	return ""
+}
+
+// DetailMessages returns the messages of the details, from the oldest to the most recently added. It is the
+// same as what [Error.Details] held before it became a []Detail.
+func (err *Error) DetailMessages() []string {
+	messages := make([]string, 0, len(err.Details))
+	for _, detail := range err.Details {
+		messages = append(messages, detail.Message)
+	}
+	return messages
+}
+
+// EachDetailReverse calls fn for each of the details, from the most recently added to the oldest. It stops
+// early if fn returns false.
+func (err *Error) EachDetailReverse(fn func(detail Detail) bool) {
+	for i := len(err.Details) - 1; i >= 0; i-- {
+		if !fn(err.Details[i]) {
+			return
+		}
+	}
+}
+
+// StripStack releases the stacktrace of an error that has already been built, e.g. one that is cached for the
+// lifetime of the program, so the frames no longer take up memory. It modifies the error in place and returns
+// it for convenience. This cannot be undone. Use [Record.DropStack] to leave the stack out while building.
+func (err *Error) StripStack() *Error {
+	err.Stack = Stack{}
+	err.CapturedAt = Stack{}
+	return err
+}
+
 // Unwrap allows you to unwrap any internal error which makes the implementation compatible with [errors.As].
 func (err *Error) Unwrap() error { return err.Wraps }
 
-// Format implements the [fmt.Formatter] interface.
+// Is reports whether the error has been tagged with target (or an error that matches target) via
+// [Record.Sentinel]. It allows [errors.Is] to match sentinels that aren't part of the wrapped chain.
+//
+// If [Record.CacheIs] was used, Is also reports whether target matches anywhere in the wrapped chain, and
+// remembers the answer per target, so repeated [errors.Is] checks that match return at the outermost error
+// instead of walking the whole chain every time. Checks that don't match still walk the chain, because
+// [errors.Is] keeps unwrapping after Is reports false.
+func (err *Error) Is(target error) bool {
+	if err.isCache == nil || target == nil || !reflect.TypeOf(target).Comparable() {
+		return err.isSentinel(target)
+	}
+	if match, ok := err.isCache.Load(target); ok {
+		return match.(bool)
+	}
+	match := err.isSentinel(target) || errors.Is(err.Wraps, target)
+	err.isCache.Store(target, match)
+	return match
+}
+
+// isSentinel reports whether target matches any of the sentinels attached with [Record.Sentinel].
+func (err *Error) isSentinel(target error) bool {
+	for _, sentinel := range err.sentinels {
+		if errors.Is(sentinel, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Format implements the [fmt.Formatter] interface. If the formatter panics, the panic is recovered and a
+// "<formatting error: ...>" marker followed by the summary of the error is written instead, so that a buggy
+// [Formatter] can never crash the program.
 func (err *Error) Format(state fmt.State, verb rune) {
-	err.f.Format(err, state, verb)
+	safeFormat(err.f, err, state, verb)
+}
+
+// safeFormat formats err with f, recovering from any panic in f the same way [Error.Format] does. Every entry point
+// that formats an [Error] ([Error.Format], [FormatTo], [Tee], [String], [VerboseString], and [FormatBytes]) goes
+// through it.
+func safeFormat(f Formatter, err *Error, state fmt.State, verb rune) {
+	defer func() {
+		if r := recover(); r != nil {
+			_, _ = fmt.Fprintf(state, "<formatting error: %v> %v", r, err.safeSummary())
+		}
+	}()
+	f.Format(err, state, verb)
+}
+
+// safeSummary is the same as [Error.Summary] but returns an empty string if the wrapped error panics.
+func (err *Error) safeSummary() (summary string) {
+	defer func() {
+		if recover() != nil {
+			summary = ""
+		}
+	}()
+	return err.Summary()
 }
 
 // KindOf returns the Kind of this error. If it cannot determine the Kind (e.g. because
@@ -88,3 +376,221 @@ func KindOf(err error) Kind {
 	}
 	return KindUnknown
 }
+
+// LevelOf returns the level of the nearest [Error] in the chain that has one set. If none of them do, it
+// returns [LevelError], since that is what an error is unless it's been marked otherwise.
+func LevelOf(err error) Level {
+	level := LevelUnset
+	walk(err, func(e *Error) bool {
+		level = e.Level
+		return level == LevelUnset
+	})
+	if level == LevelUnset {
+		return LevelError
+	}
+	return level
+}
+
+// KindsOf returns every distinct [Kind] found in the chain of err (including all branches of joined errors) in
+// the order they are encountered. Errors without a Kind are skipped. Unlike [KindOf], which only returns the
+// nearest Kind, this is useful when an aggregate error spans several kinds of failures.
+func KindsOf(err error) []Kind {
+	kinds := []Kind{}
+	walk(err, func(e *Error) bool {
+		if e.Kind == KindUnknown {
+			return true
+		}
+		for _, k := range kinds {
+			if k == e.Kind {
+				return true
+			}
+		}
+		kinds = append(kinds, e.Kind)
+		return true
+	})
+	return kinds
+}
+
+// Age returns how long the error has been propagating, measured as the time between the earliest and the
+// latest detail timestamp found in the chain. It returns false if fewer than two details in the chain carry a
+// timestamp (see [SetDetailTimestamps]).
+func Age(err error) (time.Duration, bool) {
+	earliest, latest, count := detailTimes(err)
+	if count < 2 {
+		return 0, false
+	}
+	return latest.Sub(earliest), true
+}
+
+// detailTimes returns the earliest and latest detail timestamps in the chain of err, and how many details in the
+// chain have a timestamp.
+func detailTimes(err error) (earliest, latest time.Time, count int) {
+	walk(err, func(e *Error) bool {
+		for _, detail := range e.Details {
+			if detail.Time.IsZero() {
+				continue
+			}
+			if count == 0 || detail.Time.Before(earliest) {
+				earliest = detail.Time
+			}
+			if count == 0 || detail.Time.After(latest) {
+				latest = detail.Time
+			}
+			count++
+		}
+		return true
+	})
+	return earliest, latest, count
+}
+
+// SameKind reports whether a and b have the same [Kind] as determined by [KindOf]. Messages, stacks, and
+// everything else about the errors are ignored. Use [SameOrigin] to also tell apart errors that have no Kind.
+func SameKind(a, b error) bool {
+	return KindOf(a) == KindOf(b)
+}
+
+// SameOrigin reports whether a and b are the same kind of error, in a way that stays stable across builds (e.g.
+// for de-duplicating alerts across deploys). If either error has a [Kind] (see [KindOf]), the kinds decide. Only
+// if neither has one are they compared by where they were created: the innermost frame of the nearest [Error] in
+// each chain must have the same function and file, while line numbers are ignored. Errors without a Kind and
+// without a stack are the same. Messages and everything else about the errors are always ignored.
+func SameOrigin(a, b error) bool {
+	kindA, kindB := KindOf(a), KindOf(b)
+	if kindA != KindUnknown || kindB != KindUnknown {
+		return kindA == kindB
+	}
+	topA, okA := topFrame(a)
+	topB, okB := topFrame(b)
+	if okA != okB {
+		return false
+	}
+	return topA.Function == topB.Function && topA.File == topB.File
+}
+
+// topFrame returns the innermost frame of the stack of the nearest [Error] in the chain of err.
+func topFrame(err error) (Frame, bool) {
+	e := &Error{}
+	if !errors.As(err, &e) {
+		return Frame{}, false
+	}
+	return e.Stack.Top()
+}
+
+// KindHasPrefix reports whether the [Kind] of err (see [KindOf]) falls under prefix in the kind hierarchy. Kinds
+// can be namespaced by separating their segments with dots, from the broadest to the most specific, e.g.
+// "db.conn.refused" is part of "db.conn", which in turn is part of "db". The prefix has to match whole segments,
+// so "db" matches "db" and "db.timeout" but not "dbx". A trailing ".*" (as in "db.*") is accepted and means the
+// same as the bare prefix. An empty prefix matches every error with a known Kind.
+func KindHasPrefix(err error, prefix string) bool {
+	k := string(KindOf(err))
+	if k == string(KindUnknown) {
+		return false
+	}
+	prefix = strings.TrimSuffix(prefix, ".*")
+	if prefix == "" || k == prefix {
+		return true
+	}
+	return strings.HasPrefix(k, prefix+".")
+}
+
+// RetryAfter returns the retry-after duration of the nearest [Error] in the chain that has one set.
+func RetryAfter(err error) (time.Duration, bool) {
+	var d time.Duration
+	walk(err, func(e *Error) bool {
+		d = e.RetryAfter
+		return d == 0
+	})
+	return d, d != 0
+}
+
+// Hint returns the remediation hint of the nearest [Error] in the chain that has one set.
+func Hint(err error) (string, bool) {
+	hint := ""
+	walk(err, func(e *Error) bool {
+		hint = e.Hint
+		return hint == ""
+	})
+	return hint, hint != ""
+}
+
+// OpOf returns the operation name of the nearest [Error] in the chain that has one set, so an error that is
+// wrapped without naming an operation of its own reports the operation of the error it wraps.
+func OpOf(err error) (string, bool) {
+	op := ""
+	walk(err, func(e *Error) bool {
+		op = e.Op
+		return op == ""
+	})
+	return op, op != ""
+}
+
+// StatusCode returns the HTTP status code of the nearest [Error] in the chain that has one set.
+func StatusCode(err error) (int, bool) {
+	code := 0
+	walk(err, func(e *Error) bool {
+		code = e.Status
+		return code == 0
+	})
+	return code, code != 0
+}
+
+// HasStatus reports whether any [Error] in the chain has the given HTTP status code. Unlike comparing with
+// [StatusCode], it also matches a status set further down the chain than the nearest one.
+func HasStatus(err error, code int) bool {
+	found := false
+	walk(err, func(e *Error) bool {
+		found = e.Status != 0 && e.Status == code
+		return !found
+	})
+	return found
+}
+
+// Tags returns the union of the tags of every [Error] in the chain, from the outermost to the innermost
+// error, without duplicates.
+func Tags(err error) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+	walk(err, func(e *Error) bool {
+		for _, tag := range e.Tags {
+			if !seen[tag] {
+				seen[tag] = true
+				tags = append(tags, tag)
+			}
+		}
+		return true
+	})
+	return tags
+}
+
+// AllFields returns the structured fields of every [Error] in the chain merged into a single new map, which is
+// the complete context of the failure. The chain is searched from the outermost to the innermost error, and a
+// key set on an outer error overrides the same key on the errors it wraps. The branches of a joined error are
+// all merged as well, in order, so for keys that only conflict between branches the earlier branch wins. Values
+// are returned as they were stored, so a [LazyValue] is not computed.
+func AllFields(err error) map[string]any {
+	fields := map[string]any{}
+	walk(err, func(e *Error) bool {
+		for key, value := range e.Fields {
+			if _, ok := fields[key]; !ok {
+				fields[key] = value
+			}
+		}
+		return true
+	})
+	return fields
+}
+
+// HasTag reports whether any [Error] in the chain has been tagged with tag.
+func HasTag(err error, tag string) bool {
+	found := false
+	walk(err, func(e *Error) bool {
+		for _, t := range e.Tags {
+			if t == tag {
+				found = true
+				break
+			}
+		}
+		return !found
+	})
+	return found
+}
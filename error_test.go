@@ -0,0 +1,80 @@
+package evs
+
+import (
+	"fmt"
+	"testing"
+	"time"
+)
+
+// setClock makes the package clock return the given times in order and restores it once the test is done.
+func setClock(t *testing.T, times ...time.Time) {
+	t.Helper()
+	original := now
+	t.Cleanup(func() {
+		now = original
+	})
+	now = func() time.Time {
+		next := times[0]
+		if len(times) > 1 {
+			times = times[1:]
+		}
+		return next
+	}
+}
+
+func TestAge(t *testing.T) {
+	SetDetailTimestamps(true)
+	defer SetDetailTimestamps(false)
+	start := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	setClock(t, start, start.Add(50*time.Millisecond), start.Add(200*time.Millisecond))
+	inner := New("timeout").Err()
+	outer := From(fmt.Errorf("dial: %w", inner)).Msg("connecting").Err()
+	outer = From(outer).Msg("starting up").Err()
+	age, ok := Age(outer)
+	if !ok {
+		t.Fatal("expected the age to be known")
+	}
+	if age != 200*time.Millisecond {
+		t.Fatalf("expected an age of 200ms but got %v", age)
+	}
+}
+
+func TestAge_NoTimestamps(t *testing.T) {
+	err := New("timeout").Msg("connecting").Err()
+	if _, ok := Age(err); ok {
+		t.Fatal("expected the age to be unknown")
+	}
+}
+
+func TestCaptureElapsed(t *testing.T) {
+	SetCaptureElapsed(true)
+	defer SetCaptureElapsed(false)
+	setClock(t, startTime.Add(time.Hour+83*time.Second+500*time.Millisecond))
+	err := New("bad day").DropStack().Fmt(TextFormatter()).Err().(*Error)
+	if err.Elapsed != time.Hour+83500*time.Millisecond {
+		t.Fatalf("unexpected elapsed time %v", err.Elapsed)
+	}
+	expect := "[bad day]\nt+01:01:23"
+	if result := err.Error(); result != expect {
+		t.Fatalf("expected %q but got %q", expect, result)
+	}
+}
+
+func TestDetailOffsets(t *testing.T) {
+	SetDetailTimestamps(true)
+	defer SetDetailTimestamps(false)
+	start := time.Date(2023, 9, 1, 12, 0, 0, 0, time.UTC)
+	setClock(t, start, start.Add(12*time.Millisecond), start.Add(1500*time.Millisecond))
+	inner := New("timeout").DropStack().Err()
+	outer := New("connecting").DropStack().Set(inner).Msg("giving up").
+		Fmt(NewTextFormatter(DetailOffsets(true))).Err()
+	expect := "[[+0s] timeout]\n[[+12ms] connecting [+1.5s] giving up]"
+	if result := outer.Error(); result != expect {
+		t.Fatalf("expected %q but got %q", expect, result)
+	}
+	SetDetailTimestamps(false)
+	plain := New("timeout").DropStack().Fmt(NewTextFormatter(DetailOffsets(true))).Err()
+	if result := plain.Error(); result != "[timeout]" {
+		t.Fatalf("expected details without timestamps to have no offsets but got %q", result)
+	}
+}
@@ -0,0 +1,85 @@
+// Package evsotlp converts errors into OpenTelemetry log records so they can be exported to an OTel collector.
+// It lives in its own module so the OpenTelemetry dependency stays out of the core evs package.
+package evsotlp
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/thenorthnate/evs"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+const (
+	// AttrKind holds the [evs.Kind] of the error.
+	AttrKind = "error.kind"
+	// AttrTags holds the tags of every error in the chain.
+	AttrTags = "error.tags"
+	// AttrStack holds the stacktrace as an array of "function file:line" strings.
+	AttrStack = "error.stack"
+	// AttrFieldPrefix is prepended to the key of every structured field.
+	AttrFieldPrefix = "error.field."
+)
+
+// ToLogRecord converts err into a [plog.LogRecord] whose severity follows [evs.LevelOf], so it is an error unless
+// the chain says otherwise. The body is the summary of the nearest [evs.Error] in the chain (or the error message
+// if there isn't one). The kind, tags, stack, and structured fields are stored as attributes.
+func ToLogRecord(err error) plog.LogRecord {
+	record := plog.NewLogRecord()
+	if err == nil {
+		return record
+	}
+	level := evs.LevelOf(err)
+	record.SetSeverityNumber(severityNumber(level))
+	record.SetSeverityText(level.String())
+	e := &evs.Error{}
+	if !errors.As(err, &e) {
+		record.Body().SetStr(err.Error())
+		return record
+	}
+	record.Body().SetStr(e.Summary())
+	attrs := record.Attributes()
+	if e.Kind != evs.KindUnknown {
+		attrs.PutStr(AttrKind, string(e.Kind))
+	}
+	if tags := evs.Tags(err); len(tags) > 0 {
+		slice := attrs.PutEmptySlice(AttrTags)
+		for _, tag := range tags {
+			slice.AppendEmpty().SetStr(tag)
+		}
+	}
+	if len(e.Stack.Frames) > 0 {
+		slice := attrs.PutEmptySlice(AttrStack)
+		for _, frame := range e.Stack.Frames {
+			slice.AppendEmpty().SetStr(fmt.Sprintf("%v %v:%v", frame.Function, frame.File, frame.Line))
+		}
+	}
+	for key, value := range e.Fields {
+		putValue(attrs, AttrFieldPrefix+key, value)
+	}
+	return record
+}
+
+// severityNumber returns the OpenTelemetry severity that matches level.
+func severityNumber(level evs.Level) plog.SeverityNumber {
+	switch level {
+	case evs.LevelDebug:
+		return plog.SeverityNumberDebug
+	case evs.LevelInfo:
+		return plog.SeverityNumberInfo
+	case evs.LevelWarn:
+		return plog.SeverityNumberWarn
+	case evs.LevelFatal:
+		return plog.SeverityNumberFatal
+	default:
+		return plog.SeverityNumberError
+	}
+}
+
+// putValue stores value with its native type if pcommon supports it and as a string otherwise.
+func putValue(attrs pcommon.Map, key string, value any) {
+	if err := attrs.PutEmpty(key).FromRaw(value); err != nil {
+		attrs.PutStr(key, fmt.Sprintf("%v", value))
+	}
+}
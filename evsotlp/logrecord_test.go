@@ -0,0 +1,50 @@
+package evsotlp
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/thenorthnate/evs"
+	"go.opentelemetry.io/collector/pdata/plog"
+)
+
+func TestToLogRecord(t *testing.T) {
+	err := evs.New("bad day").Kind(evs.KindIO).WithTags("transient").With("attempt", 3).Err()
+	record := ToLogRecord(err)
+	if record.Body().Str() != "bad day" {
+		t.Fatalf("unexpected body: %v", record.Body().Str())
+	}
+	if record.SeverityNumber() != plog.SeverityNumberError {
+		t.Fatalf("unexpected severity: %v", record.SeverityNumber())
+	}
+	attrs := record.Attributes()
+	if kind, ok := attrs.Get(AttrKind); !ok || kind.Str() != "IO" {
+		t.Fatalf("unexpected kind attribute: %v", kind.AsString())
+	}
+	if tags, ok := attrs.Get(AttrTags); !ok || tags.Slice().At(0).Str() != "transient" {
+		t.Fatalf("unexpected tags attribute: %v", tags.AsString())
+	}
+	if attempt, ok := attrs.Get(AttrFieldPrefix + "attempt"); !ok || attempt.Int() != 3 {
+		t.Fatalf("unexpected attempt attribute: %v", attempt.AsString())
+	}
+	if stack, ok := attrs.Get(AttrStack); !ok || stack.Slice().Len() == 0 {
+		t.Fatal("expected the stack attribute to be set")
+	}
+}
+
+func TestToLogRecord_PlainError(t *testing.T) {
+	record := ToLogRecord(errors.New("uh oh"))
+	if record.Body().Str() != "uh oh" {
+		t.Fatalf("unexpected body: %v", record.Body().Str())
+	}
+	if record.Attributes().Len() != 0 {
+		t.Fatalf("expected no attributes but got %v", record.Attributes().AsRaw())
+	}
+}
+
+func TestToLogRecord_Level(t *testing.T) {
+	record := ToLogRecord(evs.New("slow").WithLevel(evs.LevelWarn).Err())
+	if record.SeverityNumber() != plog.SeverityNumberWarn || record.SeverityText() != "WARN" {
+		t.Fatalf("unexpected severity: %v %v", record.SeverityNumber(), record.SeverityText())
+	}
+}
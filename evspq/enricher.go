@@ -0,0 +1,41 @@
+// Package evspq provides a [evs.CauseEnricher] for errors returned by the github.com/lib/pq driver. It lives
+// in its own module so the driver dependency stays out of the core evs package.
+package evspq
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/thenorthnate/evs"
+)
+
+// Register adds [Enricher] to the evs enricher registry. The returned function removes it again, the same
+// as the one returned by [evs.RegisterEnricher].
+func Register() (unregister func()) {
+	return evs.RegisterEnricher(Enricher)
+}
+
+// Enricher extracts the SQLSTATE code (and any other populated identifying fields) from a wrapped
+// [*pq.Error]. It returns nil if the error chain does not contain one.
+func Enricher(err error) map[string]any {
+	pqErr := &pq.Error{}
+	if !errors.As(err, &pqErr) {
+		return nil
+	}
+	fields := map[string]any{
+		"sqlstate":  string(pqErr.Code),
+		"condition": pqErr.Code.Name(),
+	}
+	optional := map[string]string{
+		"schema":     pqErr.Schema,
+		"table":      pqErr.Table,
+		"column":     pqErr.Column,
+		"constraint": pqErr.Constraint,
+	}
+	for key, value := range optional {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	return fields
+}
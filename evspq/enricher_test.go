@@ -0,0 +1,44 @@
+package evspq
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/thenorthnate/evs"
+)
+
+func TestEnricher(t *testing.T) {
+	err := fmt.Errorf("insert failed: %w", &pq.Error{Code: "23505", Table: "users"})
+	fields := Enricher(err)
+	if fields["sqlstate"] != "23505" {
+		t.Fatalf("expected sqlstate 23505 but got %v", fields["sqlstate"])
+	}
+	if fields["condition"] != "unique_violation" {
+		t.Fatalf("expected condition unique_violation but got %v", fields["condition"])
+	}
+	if fields["table"] != "users" {
+		t.Fatalf("expected table users but got %v", fields["table"])
+	}
+	if _, ok := fields["column"]; ok {
+		t.Fatal("column should not be set when empty")
+	}
+}
+
+func TestRegister(t *testing.T) {
+	unregister := Register()
+	cause := &pq.Error{Code: "23505"}
+	if fields := evs.From(cause).Err().(*evs.Error).Fields; fields["sqlstate"] != "23505" {
+		t.Fatalf("expected the registered enricher to run but got %v", fields)
+	}
+	unregister()
+	if fields := evs.From(cause).Err().(*evs.Error).Fields; len(fields) != 0 {
+		t.Fatalf("expected no fields after unregistering but got %v", fields)
+	}
+}
+
+func TestEnricher_OtherError(t *testing.T) {
+	if fields := Enricher(fmt.Errorf("uh oh")); fields != nil {
+		t.Fatalf("expected nil fields but got %v", fields)
+	}
+}
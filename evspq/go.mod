@@ -0,0 +1,11 @@
+module github.com/thenorthnate/evs/evspq
+
+go 1.21.1
+
+require (
+	github.com/lib/pq v1.10.9
+	github.com/thenorthnate/evs v0.0.0
+)
+
+// evs has no tagged release yet, so this module is built against the copy in this repository.
+replace github.com/thenorthnate/evs => ../
@@ -0,0 +1,17 @@
+// Package evstest provides helpers for asserting on errors in tests.
+package evstest
+
+import (
+	"testing"
+
+	"github.com/thenorthnate/evs"
+)
+
+// RequireSameKind fails the test immediately if got and want don't have the same [evs.Kind], as reported by
+// [evs.SameKind]. Messages and stacks are ignored, so two errors without a kind always match.
+func RequireSameKind(t testing.TB, got, want error) {
+	t.Helper()
+	if !evs.SameKind(got, want) {
+		t.Fatalf("expected error kind %q but got %q\ngot error: %v", evs.KindOf(want), evs.KindOf(got), got)
+	}
+}
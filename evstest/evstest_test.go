@@ -0,0 +1,50 @@
+package evstest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/thenorthnate/evs"
+)
+
+type fakeTB struct {
+	testing.TB
+	failure string
+}
+
+func (tb *fakeTB) Helper() {}
+
+func (tb *fakeTB) Fatalf(format string, args ...any) {
+	tb.failure = fmt.Sprintf(format, args...)
+}
+
+func TestRequireSameKind(t *testing.T) {
+	got := evs.New("could not read file").Kind(evs.KindIO).Err()
+	want := evs.New("io failure").Kind(evs.KindIO).Err()
+	tb := &fakeTB{}
+	RequireSameKind(tb, got, want)
+	if tb.failure != "" {
+		t.Fatalf("expected no failure but got %v", tb.failure)
+	}
+}
+
+func TestRequireSameKind_Mismatch(t *testing.T) {
+	got := evs.New("could not read file").Kind(evs.KindIO).Err()
+	want := evs.New("bad value").Kind(evs.KindValue).Err()
+	tb := &fakeTB{}
+	RequireSameKind(tb, got, want)
+	if !strings.Contains(tb.failure, `expected error kind "Value" but got "IO"`) {
+		t.Fatalf("unexpected failure message: %v", tb.failure)
+	}
+}
+
+func TestRequireSameKind_Uncoded(t *testing.T) {
+	got := evs.New("could not read file").Err()
+	want := evs.New("created somewhere else").Err()
+	tb := &fakeTB{}
+	RequireSameKind(tb, got, want)
+	if tb.failure != "" {
+		t.Fatalf("expected errors without a kind to match but got %v", tb.failure)
+	}
+}
@@ -0,0 +1,51 @@
+package evs
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+const defaultExitCode = 1
+
+var (
+	exitCodesMu sync.RWMutex
+	exitCodes   = map[Kind]int{}
+	// exit and exitOutput are variables so that tests can replace them.
+	exit                 = os.Exit
+	exitOutput io.Writer = os.Stderr
+)
+
+// RegisterExitCode sets the exit code that [ExitCode] returns for errors of the given [Kind].
+func RegisterExitCode(k Kind, code int) {
+	exitCodesMu.Lock()
+	defer exitCodesMu.Unlock()
+	exitCodes[k] = code
+}
+
+// ExitCode returns the process exit code for err: 0 if err is nil, the code registered via [RegisterExitCode]
+// for the [Kind] of err (see [KindOf]), and 1 otherwise.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	exitCodesMu.RLock()
+	code, ok := exitCodes[KindOf(err)]
+	exitCodesMu.RUnlock()
+	if !ok {
+		return defaultExitCode
+	}
+	return code
+}
+
+// CheckExit does nothing if err is nil. Otherwise it prints err (using its formatter) to stderr and exits the
+// process with the code from [ExitCode]. It is meant to be called from main, since nothing else in this package
+// ever exits the process.
+func CheckExit(err error) {
+	if err == nil {
+		return
+	}
+	_, _ = fmt.Fprintf(exitOutput, "%v\n", err)
+	exit(ExitCode(err))
+}
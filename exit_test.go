@@ -0,0 +1,45 @@
+package evs
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestExitCode(t *testing.T) {
+	RegisterExitCode("Usage", 2)
+	defer func() {
+		exitCodesMu.Lock()
+		delete(exitCodes, "Usage")
+		exitCodesMu.Unlock()
+	}()
+	if code := ExitCode(nil); code != 0 {
+		t.Fatalf("expected 0 for a nil error but got %v", code)
+	}
+	if code := ExitCode(errors.New("bad day")); code != 1 {
+		t.Fatalf("expected 1 for an error without a registered kind but got %v", code)
+	}
+	err := fmt.Errorf("parsing flags: %w", New("unknown flag").Kind("Usage").Err())
+	if code := ExitCode(err); code != 2 {
+		t.Fatalf("expected 2 for a usage error but got %v", code)
+	}
+}
+
+func TestCheckExit(t *testing.T) {
+	out := &bytes.Buffer{}
+	code := -1
+	exit, exitOutput = func(c int) { code = c }, out
+	defer func() {
+		exit, exitOutput = os.Exit, os.Stderr
+	}()
+	CheckExit(nil)
+	if code != -1 || out.Len() != 0 {
+		t.Fatal("expected a nil error to neither print nor exit")
+	}
+	CheckExit(errors.New("bad day"))
+	if code != 1 || out.String() != "bad day\n" {
+		t.Fatalf("expected exit code 1 and the error to be printed but got %v and %q", code, out.String())
+	}
+}
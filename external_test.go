@@ -1,15 +1,33 @@
 package evs_test
 
 import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log"
+	"maps"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/thenorthnate/evs"
 )
 
+// useTextFormatter pins the default formatter to the text formatter for the rest of the test, so that tests
+// comparing text output also pass when built with the evs_json tag.
+func useTextFormatter(t *testing.T) {
+	t.Helper()
+	previous := evs.GetFormatterFunc
+	evs.GetFormatterFunc = evs.TextFormatter
+	t.Cleanup(func() { evs.GetFormatterFunc = previous })
+}
+
 func ExampleNew() {
 	err := evs.New("something terrible happened!").Err()
 	if err == nil {
@@ -205,3 +223,1443 @@ func TestKind_FromNil(t *testing.T) {
 		t.Fatal("error was supposed to be nil")
 	}
 }
+
+func TestRecord_With(t *testing.T) {
+	err := evs.New("bad day").With("id", 42).Err()
+	expect := &evs.Error{}
+	if !errors.As(err, &expect) {
+		t.Fatal("expected error to have type Error but it did not")
+	}
+	if expect.Fields["id"] != 42 {
+		t.Fatalf("expected field id to be 42 but got %v", expect.Fields["id"])
+	}
+}
+
+type enrichableError struct {
+	code string
+}
+
+func (err enrichableError) Error() string { return "enrichable: " + err.code }
+
+func TestRegisterEnricher(t *testing.T) {
+	unregister := evs.RegisterEnricher(func(err error) map[string]any {
+		target := enrichableError{}
+		if !errors.As(err, &target) {
+			return nil
+		}
+		return map[string]any{"code": target.code}
+	})
+	err := evs.From(enrichableError{code: "23505"}).Err()
+	expect := &evs.Error{}
+	if !errors.As(err, &expect) {
+		t.Fatal("expected error to have type Error but it did not")
+	}
+	if expect.Fields["code"] != "23505" {
+		t.Fatalf("expected field code to be 23505 but got %v", expect.Fields["code"])
+	}
+	other := evs.From(errors.New("uh oh")).Err()
+	if !errors.As(other, &expect) {
+		t.Fatal("expected error to have type Error but it did not")
+	}
+	if len(expect.Fields) != 0 {
+		t.Fatalf("expected no fields but got %v", expect.Fields)
+	}
+	fields := evs.New("bad day").Set(enrichableError{code: "23505"}).Err().(*evs.Error).Fields
+	if fields["code"] != "23505" {
+		t.Fatalf("expected Set to enrich the error but got %v", fields)
+	}
+	unregister()
+	unregister()
+	if fields := evs.From(enrichableError{code: "23505"}).Err().(*evs.Error).Fields; len(fields) != 0 {
+		t.Fatalf("expected no fields after unregistering but got %v", fields)
+	}
+}
+
+func TestRegisterEnricher_Reentrant(t *testing.T) {
+	var unregister func()
+	unregister = evs.RegisterEnricher(func(err error) map[string]any {
+		unregister()
+		defer evs.RegisterEnricher(func(error) map[string]any { return nil })()
+		return map[string]any{"inner": evs.From(errors.New("nested")).Err().Error()}
+	})
+	done := make(chan map[string]any)
+	go func() {
+		done <- evs.From(errors.New("uh oh")).Err().(*evs.Error).Fields
+	}()
+	select {
+	case fields := <-done:
+		if fields["inner"] == nil {
+			t.Fatalf("expected the enricher to run but got %v", fields)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("enricher deadlocked")
+	}
+}
+
+func TestSummary(t *testing.T) {
+	err := evs.From(errors.New("uh oh")).Msg("first").Msg("second").Err()
+	summary := err.(*evs.Error).Summary()
+	if summary != "first" {
+		t.Fatalf("expected summary to be first but got %v", summary)
+	}
+}
+
+func TestSummary_Wrapped(t *testing.T) {
+	err := evs.From(errors.New("uh oh")).Err()
+	summary := err.(*evs.Error).Summary()
+	if summary != "uh oh" {
+		t.Fatalf("expected summary to be uh oh but got %v", summary)
+	}
+}
+
+func TestPublic(t *testing.T) {
+	useTextFormatter(t)
+	evs.RegisterPublicMessage(evs.KindValue, "invalid request")
+	err := evs.New("user id 42 failed validation").Kind(evs.KindValue).Err()
+	public := evs.Public(err)
+	if public.Error() != "[invalid request]" {
+		t.Fatalf("public error has invalid contents: %v", public.Error())
+	}
+	if public.Kind != evs.KindValue {
+		t.Fatalf("expected kind %v but got %v", evs.KindValue, public.Kind)
+	}
+}
+
+func TestPublic_Unregistered(t *testing.T) {
+	useTextFormatter(t)
+	err := evs.From(errors.New("secret connection string")).Kind(evs.KindIO).Err()
+	public := evs.Public(err)
+	if public.Error() != "[internal error]" {
+		t.Fatalf("public error has invalid contents: %v", public.Error())
+	}
+	if public.Kind != evs.KindIO {
+		t.Fatalf("expected kind %v but got %v", evs.KindIO, public.Kind)
+	}
+}
+
+func TestPublic_Nil(t *testing.T) {
+	if evs.Public(nil) != nil {
+		t.Fatal("error was supposed to be nil")
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	first := evs.New("slow down").WithRetryAfter(3 * time.Second).Err()
+	second := fmt.Errorf("request failed: %w", first)
+	d, ok := evs.RetryAfter(second)
+	if !ok || d != 3*time.Second {
+		t.Fatalf("expected retry after of 3s but got %v (%v)", d, ok)
+	}
+}
+
+func TestRetryAfter_NotSet(t *testing.T) {
+	err := evs.New("bad day").Err()
+	if _, ok := evs.RetryAfter(err); ok {
+		t.Fatal("retry after should not be set")
+	}
+}
+
+func TestStatusCode(t *testing.T) {
+	inner := evs.New("not found").WithStatus(404).Err()
+	middle := evs.New("lookup failed").Set(fmt.Errorf("query: %w", inner)).Err()
+	outer := evs.New("request failed").WithStatus(503).Set(middle).Err()
+	code, ok := evs.StatusCode(outer)
+	if !ok || code != 503 {
+		t.Fatalf("expected the nearest status of 503 but got %v (%v)", code, ok)
+	}
+	if code, _ := evs.StatusCode(middle); code != 404 {
+		t.Fatalf("expected a status of 404 but got %v", code)
+	}
+	if !evs.HasStatus(outer, 503) || !evs.HasStatus(outer, 404) {
+		t.Fatal("expected both statuses in the chain to match")
+	}
+	if evs.HasStatus(outer, 500) || evs.HasStatus(outer, 0) {
+		t.Fatal("expected statuses that aren't in the chain to not match")
+	}
+	if _, ok := evs.StatusCode(errors.New("bad day")); ok {
+		t.Fatal("status should not be set")
+	}
+}
+
+func TestHasStack(t *testing.T) {
+	err := fmt.Errorf("failed: %w", evs.New("bad day").Err())
+	if !evs.HasStack(err) {
+		t.Fatal("expected error to have a stack")
+	}
+	allocs := testing.AllocsPerRun(10, func() {
+		evs.HasStack(err)
+	})
+	if allocs != 0 {
+		t.Fatalf("expected no allocations but got %v", allocs)
+	}
+}
+
+func TestHasStack_NoStack(t *testing.T) {
+	err := fmt.Errorf("failed: %w", evs.New("bad day").DropStack().Err())
+	if evs.HasStack(err) {
+		t.Fatal("expected error to not have a stack")
+	}
+	if evs.HasStack(errors.New("uh oh")) {
+		t.Fatal("expected error to not have a stack")
+	}
+}
+
+func TestTee(t *testing.T) {
+	err := evs.New("bad day").DropStack().Err().(*evs.Error)
+	text := &bytes.Buffer{}
+	js := &bytes.Buffer{}
+	teeErr := evs.Tee(err, 'v',
+		evs.Sink{Writer: text, Formatter: evs.TextFormatter()},
+		evs.Sink{Writer: js, Formatter: evs.JSONFormatter()},
+	)
+	if teeErr != nil {
+		t.Fatalf("unexpected error: %v", teeErr)
+	}
+	if text.String() != "[bad day]" {
+		t.Fatalf("text output has invalid contents: %v", text.String())
+	}
+	if !strings.HasPrefix(js.String(), "{") || !strings.Contains(js.String(), `"details":["bad day"]`) {
+		t.Fatalf("json output has invalid contents: %v", js.String())
+	}
+}
+
+func TestFormatTo(t *testing.T) {
+	useTextFormatter(t)
+	err := evs.New("bad day").DropStack().Err().(*evs.Error)
+	buf := &bytes.Buffer{}
+	if writeErr := evs.FormatTo(buf, err, nil, 'v'); writeErr != nil {
+		t.Fatalf("unexpected error: %v", writeErr)
+	}
+	if buf.String() != "[bad day]" {
+		t.Fatalf("output has invalid contents: %v", buf.String())
+	}
+}
+
+type failingWriter struct{}
+
+func (w failingWriter) Write(b []byte) (int, error) { return 0, errors.New("disk full") }
+
+func TestFormatTo_WriteError(t *testing.T) {
+	err := evs.New("bad day").Err().(*evs.Error)
+	writeErr := evs.FormatTo(failingWriter{}, err, evs.TextFormatter(), 'v')
+	if writeErr == nil || writeErr.Error() != "disk full" {
+		t.Fatalf("expected the write error to be returned but got %v", writeErr)
+	}
+}
+
+var errNotFound = errors.New("not found")
+
+func TestWrapSentinel(t *testing.T) {
+	cause := errors.New("no rows in result set")
+	err := evs.WrapSentinel(cause, errNotFound, "looking up user")
+	if !errors.Is(err, errNotFound) {
+		t.Fatal("expected error to match the sentinel")
+	}
+	if !errors.Is(err, cause) {
+		t.Fatal("expected error to match the cause")
+	}
+	if errors.Unwrap(err) != cause {
+		t.Fatal("expected the cause to be the wrapped error")
+	}
+	if !strings.Contains(err.Error(), "no rows in result set") || !strings.Contains(err.Error(), "looking up user") {
+		t.Fatalf("error \n%v\n did not contain expected output", err.Error())
+	}
+}
+
+func TestWrapSentinel_Nil(t *testing.T) {
+	if evs.WrapSentinel(nil, errNotFound, "looking up user") != nil {
+		t.Fatal("error was supposed to be nil")
+	}
+}
+
+func TestRecord_Sentinel(t *testing.T) {
+	err := fmt.Errorf("outer: %w", evs.New("bad day").Sentinel(errNotFound).Err())
+	if !errors.Is(err, errNotFound) {
+		t.Fatal("expected error to match the sentinel")
+	}
+	if errors.Is(err, io.EOF) {
+		t.Fatal("expected error to not match io.EOF")
+	}
+}
+
+func deepChain(depth int) error {
+	err := io.EOF
+	for i := 0; i < depth; i++ {
+		err = fmt.Errorf("layer %v: %w", i, err)
+	}
+	return err
+}
+
+func TestRecord_CacheIs(t *testing.T) {
+	rec := evs.From(deepChain(50)).Sentinel(errNotFound).CacheIs()
+	err := rec.Err()
+	for i := 0; i < 2; i++ {
+		if !errors.Is(err, io.EOF) {
+			t.Fatal("expected error to match io.EOF")
+		}
+		if !errors.Is(err, errNotFound) {
+			t.Fatal("expected error to match the sentinel")
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Fatal("expected error to not match io.ErrUnexpectedEOF")
+		}
+	}
+	rec.Set(io.ErrUnexpectedEOF)
+	if errors.Is(err, io.EOF) {
+		t.Fatal("expected the cache to be reset after changing the wrapped error")
+	}
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatal("expected error to match io.ErrUnexpectedEOF")
+	}
+}
+
+func BenchmarkIs(b *testing.B) {
+	err := evs.From(deepChain(50)).Err()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = errors.Is(err, io.EOF)
+	}
+}
+
+func BenchmarkIs_Cached(b *testing.B) {
+	err := evs.From(deepChain(50)).CacheIs().Err()
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = errors.Is(err, io.EOF)
+	}
+}
+
+func TestWrapMessage(t *testing.T) {
+	useTextFormatter(t)
+	cause := fmt.Errorf("reading body: %w", io.ErrUnexpectedEOF)
+	err := evs.WrapMessage(cause, "calling upstream")
+	expect := evs.From(cause).Msg("calling upstream").Err()
+	if fmt.Sprintf("%q", err) != fmt.Sprintf("%q", expect) {
+		t.Fatalf("expected %q but got %q", expect, err)
+	}
+	if errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatal("expected the original error to be dropped")
+	}
+	if evs.WrapMessage(nil, "calling upstream") != nil {
+		t.Fatal("error was supposed to be nil")
+	}
+}
+
+func TestPromote(t *testing.T) {
+	useTextFormatter(t)
+	cause := fmt.Errorf("reading body: %w", io.ErrUnexpectedEOF)
+	err := evs.Promote(cause)
+	if err.Wraps != nil || len(err.Details) != 1 || err.Details[0].Message != cause.Error() {
+		t.Fatalf("expected the error to be flattened but got %#v", err)
+	}
+	if result := err.StripStack().Error(); result != "[reading body: unexpected EOF]" {
+		t.Fatalf("unexpected output %q", result)
+	}
+	if evs.Promote(nil) != nil {
+		t.Fatal("error was supposed to be nil")
+	}
+}
+
+func TestRecord_Prefix(t *testing.T) {
+	rec := evs.New("connection refused")
+	frames := len(rec.Err().(*evs.Error).Stack.Frames)
+	err := rec.Prefix("startup failed").Err().(*evs.Error)
+	if len(err.Details) != 1 || err.Details[0].Message != "startup failed: connection refused" {
+		t.Fatalf("expected the message to be prefixed but got %v", err.Details)
+	}
+	if len(err.Stack.Frames) != frames || err.Wraps != nil {
+		t.Fatal("expected no new layer or frame to be added")
+	}
+	empty := evs.From(io.EOF).Prefix("reading").Err().(*evs.Error)
+	if len(empty.Details) != 1 || empty.Details[0].Message != "reading" {
+		t.Fatalf("expected a detail holding the prefix but got %v", empty.Details)
+	}
+}
+
+func TestTags(t *testing.T) {
+	inner := evs.New("bad day").WithTags("transient", "billing").Err()
+	outer := evs.New("worse day").WithTags("user-facing", "transient").Set(inner).Err()
+	tags := evs.Tags(outer)
+	expect := []string{"user-facing", "transient", "billing"}
+	if strings.Join(tags, ",") != strings.Join(expect, ",") {
+		t.Fatalf("expected tags %v but got %v", expect, tags)
+	}
+	if !evs.HasTag(outer, "billing") {
+		t.Fatal("expected error to have the billing tag")
+	}
+	if evs.HasTag(outer, "fatal") {
+		t.Fatal("expected error to not have the fatal tag")
+	}
+}
+
+func TestNew_NoPackageFrames(t *testing.T) {
+	errs := []error{
+		evs.New("bad day").Err(),
+		evs.Newf("bad day %v", 1).Err(),
+		evs.From(errors.New("bad day")).Err(),
+		evs.WrapSentinel(errors.New("bad day"), errNotFound, "oops"),
+		evs.WrapMessage(errors.New("bad day"), "oops"),
+		evs.Coerce(errors.New("bad day")),
+		evs.TrimChain(errors.New("bad day"), 3),
+		evs.Promote(errors.New("bad day")),
+		evs.NewCtx(context.Background(), "bad day").Err(),
+		evs.WrapCtx(context.Background(), errors.New("bad day")).Err(),
+	}
+	for _, err := range errs {
+		frames := err.(*evs.Error).Stack.Frames
+		if len(frames) == 0 {
+			t.Fatal("expected the error to have a stack")
+		}
+		if !strings.Contains(frames[0].Function, "evs_test.TestNew_NoPackageFrames") {
+			t.Fatalf("expected the top frame to be the caller but got %v", frames[0].Function)
+		}
+	}
+}
+
+func TestDetailMessages(t *testing.T) {
+	err := evs.New("first").MsgAt(evs.LevelDebug, "second").Err().(*evs.Error)
+	if messages := err.DetailMessages(); !slices.Equal(messages, []string{"first", "second"}) {
+		t.Fatalf("unexpected messages %v", messages)
+	}
+}
+
+func TestEachDetailReverse(t *testing.T) {
+	err := evs.New("first").Msg("second").Msg("third").Err().(*evs.Error)
+	details := []string{}
+	err.EachDetailReverse(func(detail evs.Detail) bool {
+		details = append(details, detail.Message)
+		return len(details) < 2
+	})
+	if strings.Join(details, ",") != "third,second" {
+		t.Fatalf("unexpected details: %v", details)
+	}
+}
+
+func TestIsCyclic(t *testing.T) {
+	first := evs.New("first").Err().(*evs.Error)
+	second := evs.New("second").Set(fmt.Errorf("wrapped: %w", first)).Err()
+	if evs.IsCyclic(second) {
+		t.Fatal("error should not be cyclic yet")
+	}
+	first.Wraps = second
+	if !evs.IsCyclic(second) {
+		t.Fatal("error should be cyclic")
+	}
+	if !strings.Contains(second.Error(), "...[cycle detected]") {
+		t.Fatalf("error \n%v\n did not contain expected output", second.Error())
+	}
+	if evs.HasTag(second, "missing") {
+		t.Fatal("expected error to not have the missing tag")
+	}
+}
+
+func TestIsCyclic_Self(t *testing.T) {
+	err := evs.New("uh oh").Fmt(evs.JSONFormatter()).Err().(*evs.Error)
+	err.Wraps = err
+	if !evs.IsCyclic(err) {
+		t.Fatal("error should be cyclic")
+	}
+	if !strings.Contains(err.Error(), `"wraps":"...[cycle detected]"`) {
+		t.Fatalf("error \n%v\n did not contain expected output", err.Error())
+	}
+}
+
+func TestSameKind(t *testing.T) {
+	a := evs.New("bad day").Kind(evs.KindIO).Err()
+	b := fmt.Errorf("wrapped: %w", evs.New("worse day").Kind(evs.KindIO).Err())
+	if !evs.SameKind(a, b) {
+		t.Fatal("expected errors to have the same kind")
+	}
+	c := evs.New("bad value").Kind(evs.KindValue).Err()
+	if evs.SameKind(a, c) {
+		t.Fatal("expected errors to have different kinds")
+	}
+	if !evs.SameKind(newUncodedError("bad day"), evs.New("bad day").Err()) {
+		t.Fatal("expected errors without a kind to have the same kind")
+	}
+}
+
+func newUncodedError(msg string) error {
+	return evs.New(msg).Err()
+}
+
+func TestSameOrigin(t *testing.T) {
+	a := evs.New("bad day").Kind(evs.KindIO).Err()
+	b := fmt.Errorf("wrapped: %w", evs.New("worse day").Kind(evs.KindIO).Err())
+	if !evs.SameOrigin(a, b) {
+		t.Fatal("expected errors with the same kind to match")
+	}
+	if evs.SameOrigin(a, evs.New("bad value").Kind(evs.KindValue).Err()) {
+		t.Fatal("expected errors with different kinds to differ")
+	}
+}
+
+func TestSameOrigin_Uncoded(t *testing.T) {
+	a := newUncodedError("bad day")
+	b := fmt.Errorf("wrapped: %w", newUncodedError("worse day"))
+	if !evs.SameOrigin(a, b) {
+		t.Fatal("expected errors created in the same place to match")
+	}
+	if evs.SameOrigin(a, evs.New("bad day").Err()) {
+		t.Fatal("expected errors created in different places to differ")
+	}
+	if evs.SameOrigin(a, evs.New("bad day").Kind(evs.KindIO).Err()) {
+		t.Fatal("expected the kind to win over the location")
+	}
+	if !evs.SameOrigin(errors.New("bad day"), io.EOF) {
+		t.Fatal("expected errors without a kind or stack to match")
+	}
+}
+
+func newOriginError() error {
+	return evs.New("origin").Err()
+}
+
+func TestOriginStack(t *testing.T) {
+	inner := newOriginError()
+	middle := evs.New("middle").DropStack().Set(inner).Err()
+	outer := evs.New("outer").Set(fmt.Errorf("wrapped: %w", middle)).Err()
+	stack, ok := evs.OriginStack(outer)
+	if !ok {
+		t.Fatal("expected to find a stack")
+	}
+	if !strings.Contains(stack.Frames[0].Function, "newOriginError") {
+		t.Fatalf("expected the origin stack but got one starting at %v", stack.Frames[0].Function)
+	}
+}
+
+func TestOriginStack_OuterOnly(t *testing.T) {
+	inner := evs.New("inner").DropStack().Err()
+	outer := evs.New("outer").Set(inner).Err()
+	stack, ok := evs.OriginStack(outer)
+	if !ok {
+		t.Fatal("expected to find a stack")
+	}
+	if !strings.Contains(stack.Frames[0].Function, "TestOriginStack_OuterOnly") {
+		t.Fatalf("expected the outer stack but got one starting at %v", stack.Frames[0].Function)
+	}
+}
+
+func TestOriginStack_None(t *testing.T) {
+	if _, ok := evs.OriginStack(errors.New("uh oh")); ok {
+		t.Fatal("expected to not find a stack")
+	}
+}
+
+func TestString(t *testing.T) {
+	useTextFormatter(t)
+	err := evs.New("bad day").Err().(*evs.Error)
+	if evs.String(err) != fmt.Sprintf("%s", err) {
+		t.Fatalf("expected\n%s\nbut got\n%v", err, evs.String(err))
+	}
+	if evs.VerboseString(err) != err.Error() {
+		t.Fatalf("expected\n%v\nbut got\n%v", err.Error(), evs.VerboseString(err))
+	}
+}
+
+func BenchmarkVerboseString(b *testing.B) {
+	err := evs.New("bad day").Err().(*evs.Error)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = evs.VerboseString(err)
+	}
+}
+
+func BenchmarkSprintf(b *testing.B) {
+	err := evs.New("bad day").Err().(*evs.Error)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = fmt.Sprintf("%+v", err)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	err := evs.New("bad day").Err().(*evs.Error)
+	if string(evs.FormatBytes(err, 'v')) != fmt.Sprintf("%v", err) {
+		t.Fatalf("expected\n%v\nbut got\n%s", err, evs.FormatBytes(err, 'v'))
+	}
+}
+
+func BenchmarkFormatBytes(b *testing.B) {
+	err := evs.New("bad day").Err().(*evs.Error)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = evs.FormatBytes(err, 'v')
+	}
+}
+
+func BenchmarkSprintfBytes(b *testing.B) {
+	err := evs.New("bad day").Err().(*evs.Error)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = []byte(fmt.Sprintf("%v", err))
+	}
+}
+
+func TestRecord_MsgAt(t *testing.T) {
+	err := evs.New("bad day").MsgAt(evs.LevelDebug, "low level note").Err().(*evs.Error)
+	if len(err.Details) != 2 {
+		t.Fatalf("expected 2 details but got %v", len(err.Details))
+	}
+	if err.Details[1].Level != evs.LevelDebug || err.Details[1].Message != "low level note" {
+		t.Fatalf("unexpected detail: %+v", err.Details[1])
+	}
+}
+
+func TestToMap(t *testing.T) {
+	err := evs.From(errors.New("uh oh")).
+		Msg("bad day").
+		MsgAt(evs.LevelDebug, "note").
+		Kind(evs.KindIO).
+		WithTags("transient").
+		With("id", 42).
+		With("message", "ignored").
+		Err()
+	m := evs.ToMap(err)
+	if m["message"] != "bad day" {
+		t.Fatalf("unexpected message: %v", m["message"])
+	}
+	if m["wraps"] != "uh oh" {
+		t.Fatalf("unexpected wraps: %v", m["wraps"])
+	}
+	if m["kind"] != "IO" {
+		t.Fatalf("unexpected kind: %v", m["kind"])
+	}
+	if m["level"] != "ERROR" {
+		t.Fatalf("unexpected level: %v", m["level"])
+	}
+	if m := evs.ToMap(evs.New("slow").WithLevel(evs.LevelWarn).Err()); m["level"] != "WARN" {
+		t.Fatalf("unexpected level: %v", m["level"])
+	}
+	if m["id"] != 42 {
+		t.Fatalf("unexpected id: %v", m["id"])
+	}
+	if tags := m["tags"].([]string); len(tags) != 1 || tags[0] != "transient" {
+		t.Fatalf("unexpected tags: %v", tags)
+	}
+	details := m["details"].([]map[string]any)
+	if len(details) != 2 || details[1]["message"] != "note" || details[1]["level"] != "DEBUG" {
+		t.Fatalf("unexpected details: %v", details)
+	}
+	if _, ok := details[0]["level"]; ok {
+		t.Fatalf("expected no level on the first detail: %v", details[0])
+	}
+	if stack := m["stack"].([]string); !strings.Contains(stack[0], "TestToMap") {
+		t.Fatalf("unexpected stack: %v", stack)
+	}
+}
+
+func TestToMap_PlainError(t *testing.T) {
+	m := evs.ToMap(errors.New("uh oh"))
+	if len(m) != 1 || m["message"] != "uh oh" {
+		t.Fatalf("unexpected map: %v", m)
+	}
+	if evs.ToMap(nil) != nil {
+		t.Fatal("expected a nil map")
+	}
+}
+
+func TestRecord_Var(t *testing.T) {
+	useTextFormatter(t)
+	err := evs.New("bad day").Var("id", 42).Err().(*evs.Error)
+	detail := err.Details[0]
+	if len(detail.Vars) != 1 || detail.Vars[0].Key != "id" || detail.Vars[0].Value != 42 {
+		t.Fatalf("unexpected vars: %v", detail.Vars)
+	}
+	if !strings.HasSuffix(detail.Location.File, "external_test.go") {
+		t.Fatalf("expected the location to be the caller but got %v", detail.Location.File)
+	}
+	if !strings.Contains(err.Error(), "(id=42) bad day") {
+		t.Fatalf("error \n%v\n did not contain expected output", err.Error())
+	}
+}
+
+func TestHideDetailLocations(t *testing.T) {
+	err := evs.New("bad day").Var("id", 42).DropStack().
+		Fmt(evs.NewTextFormatter(evs.HideDetailLocations(true))).Err()
+	if result := err.Error(); result != "[(id=42) bad day]" {
+		t.Fatalf("unexpected output %q", result)
+	}
+}
+
+type panickingFormatter struct{}
+
+func (f panickingFormatter) Format(e *evs.Error, s fmt.State, verb rune) {
+	panic("nil stack")
+}
+
+func TestFormat_RecoversPanic(t *testing.T) {
+	err := evs.New("bad day").Fmt(panickingFormatter{}).Err()
+	result := err.Error()
+	if result != "<formatting error: nil stack> bad day" {
+		t.Fatalf("unexpected output: %v", result)
+	}
+	if result := string(evs.FormatBytes(err.(*evs.Error), 'v')); result != "<formatting error: nil stack> bad day" {
+		t.Fatalf("unexpected output from FormatBytes: %v", result)
+	}
+	buf := &bytes.Buffer{}
+	if writeErr := evs.Tee(err.(*evs.Error), 'v', evs.Sink{Writer: buf, Formatter: panickingFormatter{}}); writeErr != nil {
+		t.Fatal(writeErr)
+	}
+	if result := buf.String(); result != "<formatting error: nil stack> bad day" {
+		t.Fatalf("unexpected output from Tee: %v", result)
+	}
+}
+
+func TestFormat_NilFormatter(t *testing.T) {
+	err := &evs.Error{Details: []evs.Detail{{Message: "bad day"}}}
+	if !strings.Contains(err.Error(), "bad day") {
+		t.Fatalf("unexpected output: %v", err.Error())
+	}
+}
+
+func TestFrom_IsThroughWraps(t *testing.T) {
+	first := evs.From(sql.ErrNoRows).Msg("query").Err()
+	second := fmt.Errorf("loading user: %w", first)
+	third := evs.From(second).Msg("handling request").Err()
+	if !errors.Is(third, sql.ErrNoRows) {
+		t.Fatal("expected error to match sql.ErrNoRows")
+	}
+}
+
+func TestFrom_IsThroughJoin(t *testing.T) {
+	joined := errors.Join(evs.New("cache miss").Err(), evs.From(sql.ErrNoRows).Msg("query").Err())
+	err := evs.From(fmt.Errorf("loading user: %w", joined)).Msg("handling request").Err()
+	if !errors.Is(err, sql.ErrNoRows) {
+		t.Fatal("expected error to match sql.ErrNoRows")
+	}
+	expect := &evs.Error{}
+	if !errors.As(err, &expect) || expect.Wraps == nil {
+		t.Fatal("expected the joined error to be wrapped")
+	}
+}
+
+func TestMaxWrapDepth(t *testing.T) {
+	useTextFormatter(t)
+	evs.InspectFull = false
+	evs.SetMaxWrapDepth(5)
+	defer func() {
+		evs.InspectFull = true
+		evs.SetMaxWrapDepth(0)
+	}()
+	err := evs.New("bad day").Err()
+	for i := 0; i < 20; i++ {
+		err = evs.From(fmt.Errorf("layer %v: %w", i, err)).Err()
+	}
+	depth := 0
+	for unwrapped := err; unwrapped != nil; unwrapped = errors.Unwrap(unwrapped) {
+		if _, ok := unwrapped.(*evs.Error); ok {
+			depth++
+		}
+	}
+	if depth != 5 {
+		t.Fatalf("expected a depth of 5 but got %v", depth)
+	}
+	if strings.Count(err.Error(), "(max wrap depth reached)") != 1 {
+		t.Fatalf("expected a single max depth detail but got\n%v", err.Error())
+	}
+	shared := evs.New("shared").Err()
+	for i := 0; i < 5; i++ {
+		shared = evs.New("layer").Set(shared).Err()
+	}
+	details := len(shared.(*evs.Error).Details)
+	limited := evs.From(fmt.Errorf("outer: %w", shared)).Err()
+	if len(shared.(*evs.Error).Details) != details {
+		t.Fatal("expected the wrapped error to be left unchanged")
+	}
+	if !strings.Contains(limited.Error(), "(max wrap depth reached)") {
+		t.Fatalf("expected the max depth detail but got\n%v", limited.Error())
+	}
+}
+
+func TestMaxWrapDepth_Set(t *testing.T) {
+	evs.SetMaxWrapDepth(5)
+	defer evs.SetMaxWrapDepth(0)
+	root := errors.New("connection refused")
+	var err error = root
+	for i := 0; i < 2000; i++ {
+		err = evs.Newf("attempt %v", i).Set(err).DropStack().Err()
+	}
+	depth := 0
+	for unwrapped := err; unwrapped != nil; unwrapped = errors.Unwrap(unwrapped) {
+		if _, ok := unwrapped.(*evs.Error); ok {
+			depth++
+		}
+	}
+	if depth > 5 {
+		t.Fatalf("expected a depth of at most 5 but got %v", depth)
+	}
+	if !errors.Is(err, root) {
+		t.Fatal("expected the root cause to be kept")
+	}
+	if result := evs.ChainString(err, ""); !strings.HasPrefix(result, "attempt 1999 <- attempt 1998") {
+		t.Fatalf("expected the latest links to be kept but got %q", result)
+	}
+}
+
+func TestKindsOf(t *testing.T) {
+	joined := errors.Join(
+		evs.New("read failed").Kind(evs.KindIO).Err(),
+		evs.New("no kind").Err(),
+		fmt.Errorf("wrapped: %w", evs.New("bad value").Kind(evs.KindValue).Err()),
+		evs.New("write failed").Kind(evs.KindIO).Err(),
+	)
+	err := evs.New("batch failed").Kind(evs.KindType).Set(joined).Err()
+	kinds := evs.KindsOf(err)
+	expect := []evs.Kind{evs.KindType, evs.KindIO, evs.KindValue}
+	if fmt.Sprint(kinds) != fmt.Sprint(expect) {
+		t.Fatalf("expected kinds %v but got %v", expect, kinds)
+	}
+}
+
+func TestRecord_Lazy(t *testing.T) {
+	calls := 0
+	err := evs.New("bad day").WithLazy("dump", func() any {
+		calls++
+		return map[string]int{"size": 3}
+	}).Fmt(evs.JSONFormatter()).Err()
+	if calls != 0 {
+		t.Fatal("expected the lazy value to not be computed yet")
+	}
+	for i := 0; i < 2; i++ {
+		if !strings.Contains(err.Error(), `"dump":{"size":3}`) {
+			t.Fatalf("error \n%v\n did not contain expected output", err.Error())
+		}
+	}
+	if calls != 1 {
+		t.Fatalf("expected the lazy value to be computed once but it was computed %v times", calls)
+	}
+}
+
+func TestRecord_LazyPanic(t *testing.T) {
+	err := evs.New("bad day").WithLazy("dump", func() any {
+		panic("boom")
+	}).Err()
+	m := evs.ToMap(err)
+	if m["dump"] != "<lazy value panicked: boom>" {
+		t.Fatalf("unexpected value: %v", m["dump"])
+	}
+}
+
+func TestStripStack(t *testing.T) {
+	err := evs.New("service degraded").Err().(*evs.Error)
+	if len(err.Stack.Frames) == 0 {
+		t.Fatal("expected the error to have a stack")
+	}
+	if err.StripStack() != err {
+		t.Fatal("expected the same error to be returned")
+	}
+	if err.Stack.Frames != nil || evs.HasStack(err) {
+		t.Fatal("expected the stack to be released")
+	}
+	if strings.Contains(err.Error(), "With Stacktrace") {
+		t.Fatalf("error \n%v\n should not have a stacktrace", err.Error())
+	}
+}
+
+func TestWrapEach(t *testing.T) {
+	useTextFormatter(t)
+	errs := []error{nil, io.EOF, nil, errNotFound}
+	err := evs.WrapEach(errs, func(i int) string {
+		return fmt.Sprintf("item %v", i)
+	})
+	if !errors.Is(err, io.EOF) || !errors.Is(err, errNotFound) {
+		t.Fatal("expected error to match every wrapped error")
+	}
+	result := err.Error()
+	if !strings.Contains(result, "item 1") || !strings.Contains(result, "item 3") || strings.Contains(result, "item 0") {
+		t.Fatalf("error \n%v\n did not contain expected output", result)
+	}
+	if strings.Count(result, "With Stacktrace") != 1 {
+		t.Fatalf("expected a single stacktrace but got\n%v", result)
+	}
+	frames := err.(*evs.Error).Stack.Frames
+	if !strings.Contains(frames[0].Function, "TestWrapEach") {
+		t.Fatalf("expected the stack to start at the caller but got %v", frames[0].Function)
+	}
+}
+
+type sliceErr []string
+
+func (err sliceErr) Error() string { return strings.Join(err, ", ") }
+
+func TestWrapEach_KeepsInputs(t *testing.T) {
+	useTextFormatter(t)
+	existing := evs.New("existing").DropStack().Err()
+	err := evs.WrapEach([]error{sliceErr{"a"}, existing}, func(i int) string {
+		return fmt.Sprintf("item %v", i)
+	})
+	if !errors.Is(err, existing) {
+		t.Fatal("expected error to match the wrapped Error")
+	}
+	if result := existing.Error(); result != "[existing]" {
+		t.Fatalf("expected the input to be left alone but got %q", result)
+	}
+}
+
+func TestWrapEach_AllNil(t *testing.T) {
+	err := evs.WrapEach([]error{nil, nil}, func(i int) string { return "unused" })
+	if err != nil {
+		t.Fatal("error was supposed to be nil")
+	}
+}
+
+func TestRootCause(t *testing.T) {
+	err := evs.From(fmt.Errorf("wrapped: %w", io.EOF)).Msg("reading").Err()
+	if evs.RootCause(err) != io.EOF {
+		t.Fatalf("expected io.EOF but got %v", evs.RootCause(err))
+	}
+	joined := errors.Join(io.EOF, errNotFound)
+	if evs.RootCause(fmt.Errorf("wrapped: %w", joined)) != joined {
+		t.Fatal("expected the joined error to be the root cause")
+	}
+	if evs.RootCause(nil) != nil {
+		t.Fatal("expected a nil root cause")
+	}
+}
+
+func TestFrom_InheritsFields(t *testing.T) {
+	evs.InspectFull = false
+	defer func() {
+		evs.InspectFull = true
+	}()
+	inner := evs.New("query failed").With("request_id", "abc").With("table", "users").Err()
+	middle := evs.From(fmt.Errorf("loading: %w", inner)).With("table", "accounts").Err()
+	outer := evs.From(fmt.Errorf("handling: %w", middle)).With("attempt", 2).Err().(*evs.Error)
+	expect := map[string]any{"request_id": "abc", "table": "accounts", "attempt": 2}
+	if fmt.Sprint(outer.Fields) != fmt.Sprint(expect) {
+		t.Fatalf("expected fields %v but got %v", expect, outer.Fields)
+	}
+}
+
+func TestSet_InheritsFields(t *testing.T) {
+	inner := evs.New("query failed").With("request_id", "abc").With("table", "users").Err()
+	middle := evs.New("loading").With("table", "accounts").Set(inner).Err()
+	outer := evs.New("handling").Set(middle).With("attempt", 2).Err().(*evs.Error)
+	expect := map[string]any{"request_id": "abc", "table": "accounts", "attempt": 2}
+	if fmt.Sprint(outer.Fields) != fmt.Sprint(expect) {
+		t.Fatalf("expected fields %v but got %v", expect, outer.Fields)
+	}
+}
+
+func newLookupError(msg string) error {
+	cause := evs.New("row missing").Kind(evs.KindValue).Err()
+	return evs.From(fmt.Errorf("query: %w", cause)).Msg(msg).WithTags("db").Err()
+}
+
+func TestError_Equal(t *testing.T) {
+	a := newLookupError("looking up user").(*evs.Error)
+	b := newLookupError("looking up user").(*evs.Error)
+	if !a.Equal(b) {
+		t.Fatalf("expected\n%v\nto equal\n%v", a, b)
+	}
+	c := newLookupError("looking up account").(*evs.Error)
+	if a.Equal(c) {
+		t.Fatal("expected errors with different details to differ")
+	}
+	var nilErr *evs.Error
+	if a.Equal(nilErr) || !nilErr.Equal(nil) {
+		t.Fatal("expected nil errors to only equal each other")
+	}
+}
+
+func TestChainString(t *testing.T) {
+	root := evs.New("root msg").Err()
+	middle := fmt.Errorf("middle msg: %w", root)
+	outer := evs.New("outer msg").Set(middle).Err()
+	if result := evs.ChainString(outer, ""); result != "outer msg <- middle msg <- root msg" {
+		t.Fatalf("unexpected chain %q", result)
+	}
+	joined := evs.New("batch failed").Set(errors.Join(outer, io.EOF)).Err()
+	expect := "batch failed | [outer msg | middle msg | root msg, EOF]"
+	if result := evs.ChainString(joined, " | "); result != expect {
+		t.Fatalf("expected %q but got %q", expect, result)
+	}
+	if evs.ChainString(nil, "") != "" {
+		t.Fatal("expected an empty string for a nil error")
+	}
+}
+
+func TestCoerce(t *testing.T) {
+	existing := evs.New("bad day").Err().(*evs.Error)
+	if evs.Coerce(existing) != existing {
+		t.Fatal("expected an existing error to be returned unchanged")
+	}
+	cause := fmt.Errorf("outer: %w", existing)
+	coerced := evs.Coerce(cause)
+	if coerced.Wraps != cause || len(coerced.Details) != 0 {
+		t.Fatalf("expected a new error wrapping the cause but got %#v", coerced)
+	}
+	if evs.Coerce(nil) != nil {
+		t.Fatal("error was supposed to be nil")
+	}
+}
+
+func TestRecord_WithErrorValues(t *testing.T) {
+	secondary := evs.New("rollback failed").DropStack().Err()
+	rec := evs.New("commit failed").DropStack().
+		With("cleanup", errors.New("close failed")).
+		With("secondary", secondary)
+
+	text := rec.Fmt(evs.NewTextFormatter(evs.ShowFields(true))).Err().Error()
+	expect := "[commit failed]\nfields: cleanup=close failed secondary=rollback failed"
+	if text != expect {
+		t.Fatalf("expected %q but got %q", expect, text)
+	}
+
+	data := []byte(fmt.Sprintf("%v", rec.Fmt(evs.JSONFormatter()).Err()))
+	var out struct {
+		Fields struct {
+			Cleanup   string `json:"cleanup"`
+			Secondary struct {
+				Details []string `json:"details"`
+			} `json:"secondary"`
+		} `json:"fields"`
+	}
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("invalid JSON %s: %v", data, err)
+	}
+	if out.Fields.Cleanup != "close failed" {
+		t.Fatalf("expected the plain error to be written as its message but got %s", data)
+	}
+	if len(out.Fields.Secondary.Details) != 1 || out.Fields.Secondary.Details[0] != "rollback failed" {
+		t.Fatalf("expected the Error to be written in its structured form but got %s", data)
+	}
+}
+
+type tenantKey struct{}
+
+func TestSetContextExtractor(t *testing.T) {
+	evs.SetContextExtractor(func(ctx context.Context) map[string]any {
+		tenant, _ := ctx.Value(tenantKey{}).(string)
+		return map[string]any{"tenant": tenant}
+	})
+	defer evs.SetContextExtractor(nil)
+	ctx := context.WithValue(context.Background(), tenantKey{}, "acme")
+
+	err := evs.NewCtx(ctx, "bad day").Err().(*evs.Error)
+	if err.Fields["tenant"] != "acme" {
+		t.Fatalf("expected the tenant to be extracted but got %v", err.Fields)
+	}
+	wrapped := evs.WrapCtx(ctx, errors.New("bad day")).Err().(*evs.Error)
+	if wrapped.Fields["tenant"] != "acme" {
+		t.Fatalf("expected the tenant to be extracted but got %v", wrapped.Fields)
+	}
+	existing := evs.New("bad day").With("tenant", "initech").Err()
+	wrapped = evs.WrapCtx(ctx, existing).Err().(*evs.Error)
+	if wrapped.Fields["tenant"] != "initech" {
+		t.Fatalf("expected the existing field to be kept but got %v", wrapped.Fields)
+	}
+	if evs.WrapCtx(ctx, nil).Err() != nil {
+		t.Fatal("error was supposed to be nil")
+	}
+	evs.SetContextExtractor(nil)
+	if err := evs.NewCtx(ctx, "bad day").Err().(*evs.Error); err.Fields != nil {
+		t.Fatalf("expected no fields without an extractor but got %v", err.Fields)
+	}
+}
+
+func produceAsync(errs chan<- error) {
+	errs <- evs.New("bad day").Err()
+}
+
+func recaptureAsync(err error) *evs.Error {
+	return evs.WithCapturedAt(err)
+}
+
+func TestWithCapturedAt(t *testing.T) {
+	useTextFormatter(t)
+	errs := make(chan error, 1)
+	go produceAsync(errs)
+	err := evs.WithCapturedAt(<-errs)
+	result := err.Error()
+	produced := strings.Index(result, "evs_test.produceAsync")
+	continued := strings.Index(result, "Continued from:\ngithub.com/thenorthnate/evs_test.TestWithCapturedAt")
+	if produced < 0 || continued < produced {
+		t.Fatalf("expected both stacks in the output but got\n%v", result)
+	}
+	captured := err.CapturedAt
+	if again := recaptureAsync(err); !slices.Equal(again.CapturedAt.Frames, captured.Frames) {
+		t.Fatal("expected the first capture to be kept")
+	}
+	if evs.WithCapturedAt(nil) != nil {
+		t.Fatal("error was supposed to be nil")
+	}
+	plain := evs.WithCapturedAt(errors.New("bad day"))
+	if len(plain.CapturedAt.Frames) != 0 || len(plain.Stack.Frames) == 0 {
+		t.Fatal("expected a new error to only have its own stack")
+	}
+}
+
+func TestHelpURL(t *testing.T) {
+	useTextFormatter(t)
+	evs.RegisterHelpURL("Quota", "https://docs.example.com/errors/quota")
+	rec := evs.New("quota exceeded").Kind("Quota").DropStack()
+	url, ok := evs.HelpURL(fmt.Errorf("uploading: %w", rec.Err()))
+	if !ok || url != "https://docs.example.com/errors/quota" {
+		t.Fatalf("expected the registered url but got %q (%v)", url, ok)
+	}
+	if _, ok := evs.HelpURL(evs.New("bad day").Kind("Unregistered").Err()); ok {
+		t.Fatal("expected no url for an unregistered kind")
+	}
+	if result := rec.Err().Error(); result != "[quota exceeded]" {
+		t.Fatalf("expected no url by default but got %q", result)
+	}
+	text := rec.Fmt(evs.NewTextFormatter(evs.ShowHelpURL(true))).Err().Error()
+	if text != "[quota exceeded] (see https://docs.example.com/errors/quota)" {
+		t.Fatalf("unexpected output %q", text)
+	}
+	data := fmt.Sprintf("%v", rec.Fmt(evs.NewJSONFormatter(evs.JSONIncludeHelpURL(true))).Err())
+	if !strings.Contains(data, `"help_url":"https://docs.example.com/errors/quota"`) {
+		t.Fatalf("expected the url in the JSON output but got %v", data)
+	}
+}
+
+func TestHint(t *testing.T) {
+	useTextFormatter(t)
+	inner := evs.New("connection refused").WithHint("check the DATABASE_URL env var").DropStack().Err()
+	err := fmt.Errorf("starting up: %w", inner)
+	hint, ok := evs.Hint(err)
+	if !ok || hint != "check the DATABASE_URL env var" {
+		t.Fatalf("expected the hint but got %q (%v)", hint, ok)
+	}
+	if _, ok := evs.Hint(errors.New("bad day")); ok {
+		t.Fatal("hint should not be set")
+	}
+	if result := inner.Error(); result != "[connection refused]\nhint: check the DATABASE_URL env var" {
+		t.Fatalf("unexpected output %q", result)
+	}
+	data := fmt.Sprintf("%j", inner)
+	if !strings.Contains(data, `"hint":"check the DATABASE_URL env var"`) {
+		t.Fatalf("expected the hint in the JSON output but got %v", data)
+	}
+	data = fmt.Sprintf("%j", evs.New("bad day").Set(err).Err())
+	if !strings.Contains(data, `"hint":"check the DATABASE_URL env var"`) {
+		t.Fatalf("expected the wrapped hint in the JSON output but got %v", data)
+	}
+}
+
+func TestOpOf(t *testing.T) {
+	useTextFormatter(t)
+	inner := evs.New("connection refused").WithOp("db.Query").DropStack().Err()
+	wrapped := fmt.Errorf("loading user: %w", evs.New("retrying").Set(inner).DropStack().Err())
+	op, ok := evs.OpOf(wrapped)
+	if !ok || op != "db.Query" {
+		t.Fatalf("expected the op of the wrapped error but got %q (%v)", op, ok)
+	}
+	outer := evs.New("loading user").Set(inner).WithOp("user.Load").DropStack().Err()
+	if op, _ := evs.OpOf(outer); op != "user.Load" {
+		t.Fatalf("expected the nearest op to win but got %q", op)
+	}
+	if _, ok := evs.OpOf(errors.New("bad day")); ok {
+		t.Fatal("op should not be set")
+	}
+	if result := inner.Error(); result != "[connection refused]\nop=db.Query" {
+		t.Fatalf("unexpected output %q", result)
+	}
+	data := fmt.Sprintf("%j", evs.New("retrying").Set(inner).DropStack().Err())
+	if !strings.Contains(data, `"op":"db.Query"`) {
+		t.Fatalf("expected the inherited op in the JSON output but got %v", data)
+	}
+	if m := evs.ToMap(wrapped); m["op"] != "db.Query" {
+		t.Fatalf("expected the op in the map but got %v", m["op"])
+	}
+}
+
+func TestKindHasPrefix(t *testing.T) {
+	refused := fmt.Errorf("dialing: %w", evs.New("connection refused").Kind("db.conn.refused").Err())
+	for _, prefix := range []string{"db.conn.refused", "db.conn", "db", "db.*", "db.conn.*", ""} {
+		if !evs.KindHasPrefix(refused, prefix) {
+			t.Fatalf("expected %q to match the prefix %q", evs.KindOf(refused), prefix)
+		}
+	}
+	for _, prefix := range []string{"db.timeout", "db.con", "d", "dbx", "conn"} {
+		if evs.KindHasPrefix(refused, prefix) {
+			t.Fatalf("expected %q to not match the prefix %q", evs.KindOf(refused), prefix)
+		}
+	}
+	if evs.KindHasPrefix(errors.New("bad day"), "") {
+		t.Fatal("expected an error without a kind to not match")
+	}
+}
+
+type closer struct {
+	err    error
+	closed bool
+}
+
+func (c *closer) Close() error {
+	c.closed = true
+	return c.err
+}
+
+func closeAfter(c io.Closer, workErr error) (err error) {
+	defer evs.CloseWrap(&err, c, "closing file")
+	return workErr
+}
+
+func TestCloseWrap(t *testing.T) {
+	c := &closer{}
+	if err := closeAfter(c, nil); err != nil || !c.closed {
+		t.Fatalf("expected the closer to be closed without an error but got %v", err)
+	}
+	c = &closer{err: io.ErrClosedPipe}
+	err := closeAfter(c, nil)
+	if !errors.Is(err, io.ErrClosedPipe) {
+		t.Fatalf("expected the close error but got %v", err)
+	}
+	frames := err.(*evs.Error).Stack.Frames
+	if err.(*evs.Error).Details[0].Message != "closing file" || !strings.Contains(frames[0].Function, "closeAfter") {
+		t.Fatalf("expected the close error to be wrapped at the call site but got\n%v", err)
+	}
+	err = closeAfter(&closer{err: io.ErrClosedPipe}, io.ErrUnexpectedEOF)
+	if !errors.Is(err, io.ErrClosedPipe) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("expected both errors to be kept but got %v", err)
+	}
+}
+
+func TestRegisterWrapRenderer(t *testing.T) {
+	useTextFormatter(t)
+	evs.RegisterWrapRenderer((*fs.PathError)(nil), func(err error) string {
+		pathErr := err.(*fs.PathError)
+		return fmt.Sprintf("%v %q: %v", pathErr.Op, pathErr.Path, pathErr.Err)
+	})
+	defer evs.RegisterWrapRenderer((*fs.PathError)(nil), nil)
+	cause := &fs.PathError{Op: "open", Path: "/etc/app.conf", Err: fs.ErrNotExist}
+	err := evs.From(cause).Msg("loading config").DropStack().Err()
+	expect := "open \"/etc/app.conf\": file does not exist\n[loading config]"
+	if result := err.Error(); result != expect {
+		t.Fatalf("expected %q but got %q", expect, result)
+	}
+	other := evs.From(errors.New("bad day")).DropStack().Err()
+	if result := other.Error(); result != "bad day\n[]" {
+		t.Fatalf("expected unregistered types to render as before but got %q", result)
+	}
+}
+
+func runTransaction(scope *evs.Scope, attempts []error) (err error) {
+	defer func() {
+		if err != nil {
+			err = scope.Flush()
+			return
+		}
+		scope.Discard()
+	}()
+	for _, attemptErr := range attempts {
+		scope.Add(attemptErr)
+		err = attemptErr
+	}
+	return err
+}
+
+func TestScope(t *testing.T) {
+	scope := &evs.Scope{}
+	if err := runTransaction(scope, []error{io.ErrUnexpectedEOF, nil}); err != nil {
+		t.Fatalf("expected the errors to be discarded but got %v", err)
+	}
+	if scope.Flush() != nil {
+		t.Fatal("expected the scope to be empty after discarding")
+	}
+	err := runTransaction(scope, []error{io.ErrUnexpectedEOF, io.ErrClosedPipe})
+	if !errors.Is(err, io.ErrUnexpectedEOF) || !errors.Is(err, io.ErrClosedPipe) {
+		t.Fatalf("expected both errors to be flushed but got %v", err)
+	}
+	if _, ok := err.(*evs.Error); !ok {
+		t.Fatalf("expected an *evs.Error but got %T", err)
+	}
+	if scope.Flush() != nil {
+		t.Fatal("expected the scope to be empty after flushing")
+	}
+}
+
+func dialHost() error {
+	return evs.New("timeout").DropStack().Err()
+}
+
+func TestSetAnnotateCaller(t *testing.T) {
+	useTextFormatter(t)
+	if result := dialHost().Error(); result != "[timeout]" {
+		t.Fatalf("expected no caller by default but got %q", result)
+	}
+	evs.SetAnnotateCaller(true)
+	defer evs.SetAnnotateCaller(false)
+	if result := dialHost().Error(); result != "[dialHost: timeout]" {
+		t.Fatalf("expected the caller to be annotated but got %q", result)
+	}
+	err := evs.WrapSentinel(dialHost(), errNotFound, "connecting")
+	if result := err.(*evs.Error).StripStack().Error(); result != "[dialHost: timeout TestSetAnnotateCaller: connecting]" {
+		t.Fatalf("expected the caller of the wrap to be annotated but got %q", result)
+	}
+	withVar := evs.New("timeout").Var("host", "db-1").DropStack().Fmt(evs.NewTextFormatter(evs.HideDetailLocations(true))).Err()
+	if result := withVar.Error(); result != "[(host=db-1) TestSetAnnotateCaller: timeout]" {
+		t.Fatalf("expected the caller of a detail with vars to be annotated but got %q", result)
+	}
+}
+
+func TestMaxFields(t *testing.T) {
+	rec := evs.New("bad day").DropStack().With("a", 1).With("b", 2).With("c", 3).With("d", 4)
+	text := rec.Fmt(evs.NewTextFormatter(evs.ShowFields(true), evs.MaxFields(2))).Err().Error()
+	if text != "[bad day]\nfields: a=1 b=2 (+2 more fields)" {
+		t.Fatalf("unexpected output %q", text)
+	}
+	data := fmt.Sprintf("%v", rec.Fmt(evs.NewJSONFormatter(evs.JSONMaxFields(3))).Err())
+	if !strings.Contains(data, `"fields":{"a":1,"b":2,"c":3},"fields_omitted":1`) {
+		t.Fatalf("expected the fields to be capped but got %v", data)
+	}
+	text = rec.Fmt(evs.NewTextFormatter(evs.ShowFields(true))).Err().Error()
+	if text != "[bad day]\nfields: a=1 b=2 c=3 d=4" {
+		t.Fatalf("expected every field by default but got %q", text)
+	}
+}
+
+func TestBytesAndDurationFields(t *testing.T) {
+	rec := evs.New("bad day").DropStack().With("size", evs.Bytes(1_500_000)).With("timeout", 3*time.Second)
+	text := rec.Fmt(evs.NewTextFormatter(evs.ShowFields(true))).Err().Error()
+	if text != "[bad day]\nfields: size=1.5MB timeout=3s" {
+		t.Fatalf("unexpected output %q", text)
+	}
+	data := fmt.Sprintf("%v", rec.Fmt(evs.JSONFormatter()).Err())
+	if !strings.Contains(data, `"fields":{"size":1500000,"timeout":3000000000}`) {
+		t.Fatalf("expected the fields to stay numeric in JSON but got %v", data)
+	}
+	for b, expect := range map[evs.Bytes]string{0: "0B", 512: "512B", 1000: "1KB", 999_999: "1MB", 3_000_000_000: "3GB", -2500: "-2.5KB"} {
+		if result := b.String(); result != expect {
+			t.Fatalf("expected %v to be %q but got %q", int64(b), expect, result)
+		}
+	}
+}
+
+func TestAsError(t *testing.T) {
+	if _, ok := evs.AsError(errors.New("bad day")); ok {
+		t.Fatal("expected no Error in a plain error")
+	}
+	if _, ok := evs.AsError(nil); ok {
+		t.Fatal("expected no Error in a nil error")
+	}
+	inner := evs.New("connection refused").Kind(evs.KindIO).Err()
+	e, ok := evs.AsError(fmt.Errorf("dialing: %w", inner))
+	if !ok || e != inner {
+		t.Fatalf("expected the wrapped Error but got %v (%v)", e, ok)
+	}
+	outer := evs.New("loading user").Set(fmt.Errorf("dialing: %w", inner)).Err()
+	if e, _ := evs.AsError(fmt.Errorf("handler: %w", outer)); e != outer {
+		t.Fatalf("expected the outermost Error but got %v", e)
+	}
+	joined := errors.Join(inner, errors.New("bad day"))
+	if _, ok := evs.AsError(joined); ok {
+		t.Fatal("expected joined errors not to be searched")
+	}
+	wrapper := evs.New("both failed").Set(joined).Err()
+	if e, _ := evs.AsError(wrapper); e != wrapper {
+		t.Fatalf("expected the Error wrapping the join but got %v", e)
+	}
+}
+
+func TestSetErrorStringMode(t *testing.T) {
+	useTextFormatter(t)
+	defer evs.SetErrorStringMode(evs.ErrorStringFormatted)
+	root := errors.New("connection refused")
+	err := evs.New("loading user").Set(evs.New("dialing").Set(root).Err()).Err()
+	for mode, expect := range map[evs.ErrorStringMode]string{
+		evs.MessageOnly: "loading user",
+		evs.WithCause:   "loading user <- dialing",
+		evs.FullChain:   "loading user <- dialing <- connection refused",
+	} {
+		evs.SetErrorStringMode(mode)
+		if result := err.Error(); result != expect {
+			t.Fatalf("expected %q in mode %v but got %q", expect, mode, result)
+		}
+		if result := fmt.Sprintf("%v", err); !strings.Contains(result, "With Stacktrace:") {
+			t.Fatalf("expected %%v to stay formatter-driven in mode %v but got %q", mode, result)
+		}
+	}
+	evs.SetErrorStringMode(evs.ErrorStringFormatted)
+	if result := err.Error(); !strings.Contains(result, "With Stacktrace:") {
+		t.Fatalf("expected the formatted output by default but got %q", result)
+	}
+}
+
+func TestSetErrorStringMode_FormattersUnaffected(t *testing.T) {
+	useTextFormatter(t)
+	defer evs.SetErrorStringMode(evs.ErrorStringFormatted)
+	inner := evs.New("inner").Msg("more").DropStack().Err()
+	wrapped := fmt.Errorf("dialing: %w", inner)
+	joined := errors.Join(wrapped, evs.New("other").DropStack().Err())
+	outputs := func() []string {
+		return []string{
+			fmt.Sprintf("%v", evs.New("outer").Set(wrapped).DropStack().Fmt(evs.JSONFormatter()).Err()),
+			fmt.Sprintf("%v", evs.New("outer").Set(joined).DropStack().Err()),
+			evs.SingleLine(evs.New("outer").Set(wrapped).DropStack().Err()),
+		}
+	}
+	expect := outputs()
+	if !strings.Contains(expect[0], `"wraps":"dialing: [inner more]"`) {
+		t.Fatalf("unexpected JSON output %v", expect[0])
+	}
+	for _, mode := range []evs.ErrorStringMode{evs.MessageOnly, evs.WithCause, evs.FullChain} {
+		evs.SetErrorStringMode(mode)
+		if result := outputs(); !slices.Equal(result, expect) {
+			t.Fatalf("expected the same output in mode %v but got\n%v\ninstead of\n%v", mode, result, expect)
+		}
+	}
+}
+
+func TestAllFields(t *testing.T) {
+	inner := evs.New("connection refused").With("host", "db-1").With("attempt", 1).Err()
+	middle := fmt.Errorf("dialing: %w", inner)
+	outer := evs.New("loading user").Set(middle).With("attempt", 3).With("user", "alice").Err()
+	// Fields added to an inner error after it was wrapped are only found by walking the chain.
+	evs.Coerce(inner).Fields["port"] = 5432
+	fields := evs.AllFields(fmt.Errorf("handler: %w", outer))
+	expect := map[string]any{"host": "db-1", "port": 5432, "attempt": 3, "user": "alice"}
+	if !maps.Equal(fields, expect) {
+		t.Fatalf("expected %v but got %v", expect, fields)
+	}
+	joined := errors.Join(
+		evs.New("first").With("branch", "first").With("a", 1).Err(),
+		evs.New("second").With("branch", "second").With("b", 2).Err(),
+	)
+	fields = evs.AllFields(evs.New("both failed").Set(joined).With("a", 0).Err())
+	expect = map[string]any{"branch": "first", "a": 0, "b": 2}
+	if !maps.Equal(fields, expect) {
+		t.Fatalf("expected %v but got %v", expect, fields)
+	}
+	if fields := evs.AllFields(errors.New("bad day")); len(fields) != 0 {
+		t.Fatalf("expected no fields but got %v", fields)
+	}
+}
+
+func TestTrimChain(t *testing.T) {
+	useTextFormatter(t)
+	root := errors.New("connection refused")
+	var err error = root
+	for _, msg := range []string{"dialing", "connecting", "querying", "loading user", "handling request"} {
+		err = evs.New(msg).Set(err).DropStack().Err()
+	}
+	trimmed := evs.TrimChain(fmt.Errorf("server: %w", err), 2)
+	if result := evs.ChainString(trimmed, ""); result != "server <- handling request <- connection refused" {
+		t.Fatalf("unexpected chain %q", result)
+	}
+	if result := trimmed.Error(); !strings.Contains(result, "[handling request (4 links dropped)]") {
+		t.Fatalf("expected the number of dropped links in the output but got %q", result)
+	}
+	if !errors.Is(trimmed, root) {
+		t.Fatal("expected the root cause to be kept")
+	}
+	if result := evs.ChainString(err, ""); result != "handling request <- loading user <- querying <- connecting <- dialing <- connection refused" {
+		t.Fatalf("expected the original chain to be untouched but got %q", result)
+	}
+	shallow := evs.New("dialing").Set(root).Err()
+	if evs.TrimChain(shallow, 2) != shallow {
+		t.Fatal("expected a short enough chain to be returned as is")
+	}
+	if evs.TrimChain(nil, 2) != nil {
+		t.Fatal("expected nil for a nil error")
+	}
+}
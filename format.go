@@ -1,18 +1,60 @@
 package evs
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"sort"
+	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
+// JSONSchemaVersion is the version of the object layout written by the [JSONFormatter]. It is included in the
+// output as "version" and is bumped whenever the layout changes:
+//   - Version 1 was the original layout, in which stack frames used the Go field names as keys.
+//   - Version 2 switched stack frames to the "line", "file", and "function" keys and added "file_base".
+//   - Version 3 added the top-level "message".
+//   - Version 4 leaves out "wraps", "stack", and "details" when they are empty (see [JSONOmitEmpty]).
+//   - Version 5 added the "build" object (see [JSONIncludeBuildInfo]).
+const JSONSchemaVersion = 5
+
+const (
+	// DefaultStackHeader is what the text formatter writes between the details and the stack frames. Custom
+	// formatters can use it (and [DefaultFrameSeparator]) to stay visually consistent with the default output.
+	DefaultStackHeader = "\n\nWith Stacktrace:\n"
+	// CapturedAtStackHeader is what the text formatter writes before the frames of [Error.CapturedAt].
+	CapturedAtStackHeader = "\n\nContinued from:\n"
+	// DefaultFrameSeparator is what the text formatter writes between two stack frames.
+	DefaultFrameSeparator = "\n"
+)
+
+// cycleDetected is written in place of a wrapped error that leads back to the error being formatted.
+const cycleDetected = "...[cycle detected]"
+
 var (
 	// GetFormatterFunc should return the formatter that gets used in each instantiation of an error. You can
 	// supply your own implementation if you would like to change how errors are formatted. See the source
-	// code for the [textFormatter] to see how it is implemented.
+	// code for the [textFormatter] to see how it is implemented. The default can also be picked at build time
+	// with a tag: evs_json selects the [JSONFormatter] and evs_text the [TextFormatter]. If both are set,
+	// evs_json wins. Assignments in your own code still take precedence, since they run after this package
+	// is initialized.
 	GetFormatterFunc = TextFormatter
+	// deterministicStacks is set via [SetDeterministicStacks].
+	deterministicStacks atomic.Bool
 )
 
+// SetDeterministicStacks controls whether formatted stack frames and detail locations hide the parts that
+// change as code moves around, so that golden/snapshot tests stay stable. The text formatter writes
+// "file.go:NN" instead of the line number, and the JSON formatter writes a "line" of 0. In both, file paths
+// are reduced to their base names (even with [FullFilePaths]), since the directories differ between machines.
+// Function names are kept. By default it is off.
+func SetDeterministicStacks(on bool) {
+	deterministicStacks.Store(on)
+}
+
 // Formatter is almost the same as the [fmt.Formatter] but passes the [Error] in as well.
 type Formatter interface {
 	Format(e *Error, f fmt.State, verb rune)
@@ -24,52 +66,1066 @@ func TextFormatter() Formatter {
 	return textFormatter{}
 }
 
+// TextOption configures the [Formatter] returned by [NewTextFormatter].
+type TextOption func(f *textFormatter)
+
+// NewTextFormatter returns the default text [Formatter] configured with the given options. Calling it
+// without any options gives the same result as [TextFormatter].
+func NewTextFormatter(opts ...TextOption) Formatter {
+	f := textFormatter{}
+	for _, opt := range opts {
+		opt(&f)
+	}
+	return f
+}
+
+// AlignFrames pads the function names in the stacktrace so that the [file:line] locations line up in a
+// single column. It only affects the long form of the frames (e.g. %v). By default it is off.
+func AlignFrames(on bool) TextOption {
+	return func(f *textFormatter) {
+		f.alignFrames = on
+	}
+}
+
+// ShowBuildInfo adds a "build=<version> (<commit>)" line to the output using the values from [SetBuildInfo],
+// and a "revision=<revision> (<commit time>)" line using the values from [VCSInfo] if the binary has them. The
+// revision line ends in ", modified" when the binary was built from a modified working tree. By default it is
+// off.
+func ShowBuildInfo(on bool) TextOption {
+	return func(f *textFormatter) {
+		f.showBuildInfo = on
+	}
+}
+
+// ShowSummary starts the output with a "summary: <message> (caused by: <root cause>)" headline, followed by the
+// usual output. The root cause is found via [RootCause] and is left out if the error has no cause. By default
+// it is off.
+func ShowSummary(on bool) TextOption {
+	return func(f *textFormatter) {
+		f.showSummary = on
+	}
+}
+
+// MaxFields limits the "fields:" line of [ShowFields] to the first n fields (by key), followed by a
+// "(+M more fields)" marker if any were left out. By default (or if n is zero or less) every field is shown.
+func MaxFields(n int) TextOption {
+	return func(f *textFormatter) {
+		f.maxFields = n
+	}
+}
+
+// ShowHelpURL appends " (see <url>)" to the details when a documentation URL has been registered for the
+// [Kind] of the error (see [RegisterHelpURL]). By default it is off.
+func ShowHelpURL(on bool) TextOption {
+	return func(f *textFormatter) {
+		f.showHelpURL = on
+	}
+}
+
+// ShowHostInfo adds a "host=<hostname>" line to the output using the value from [SetHostInfo]. By default it
+// is off.
+func ShowHostInfo(on bool) TextOption {
+	return func(f *textFormatter) {
+		f.showHostInfo = on
+	}
+}
+
+// HidePackageFrames drops any frames belonging to the evs package itself from the top of the stacktrace. The
+// constructors already skip their own frames, so this only matters when a helper miscounts its skip value.
+// By default it is off.
+func HidePackageFrames(on bool) TextOption {
+	return func(f *textFormatter) {
+		f.hidePackageFrames = on
+	}
+}
+
+// ReverseDetails prints the details newest-first instead of in the order they were added. This puts the most
+// recent context at the front, which is often the most relevant when tailing logs. By default it is off.
+func ReverseDetails(on bool) TextOption {
+	return func(f *textFormatter) {
+		f.reverseDetails = on
+	}
+}
+
+// DedupeWrappedMessage leaves out the line of the wrapped error when one of the details already contains its
+// message, e.g. when the cause was folded into a message via fmt.Sprintf. Wrapped [Error]s are always shown.
+// By default it is off.
+func DedupeWrappedMessage(on bool) TextOption {
+	return func(f *textFormatter) {
+		f.dedupeWrappedMessage = on
+	}
+}
+
+// DedupeStackFrames shortens the stack of an error that wraps another [Error] by leaving out the outermost frames
+// that it shares with the stack of the wrapped error, which is printed above it. The shared frames are replaced
+// by a single "... (same as above)" line. This mostly helps chains of errors captured at nearby call sites. It
+// has no effect with [StackFirst], since the stacks are then printed before the wrapped error. By default it is
+// off.
+func DedupeStackFrames(on bool) TextOption {
+	return func(f *textFormatter) {
+		f.dedupeStackFrames = on
+	}
+}
+
+// HideDetailLocations leaves out the "[file:line]" prefix of details that carry a location (those added via
+// [Record.Var]), which is useful when the output is shown to end users. By default the locations are shown.
+func HideDetailLocations(on bool) TextOption {
+	return func(f *textFormatter) {
+		f.hideDetailLocations = on
+	}
+}
+
+// DetailOffsets prefixes every detail that has a timestamp (see [SetDetailTimestamps]) with how long after the
+// earliest detail in the chain it was added, e.g. "[+12ms] retrying". This shows where the time was spent along
+// the path of the error. Details without a timestamp are printed as usual. By default it is off.
+func DetailOffsets(on bool) TextOption {
+	return func(f *textFormatter) {
+		f.detailOffsets = on
+	}
+}
+
+// MinDetailLevel hides any details whose level is below the given level. Details without a level (e.g. those
+// added via [Record.Msg]) are always shown. Use a formatter without this option when you want verbose output.
+// By default every detail is shown.
+func MinDetailLevel(level Level) TextOption {
+	return func(f *textFormatter) {
+		f.minDetailLevel = level
+	}
+}
+
+// StackMinLevel only prints the stacktrace of errors whose level (see [LevelOf]) is at or above the given level,
+// e.g. to keep stacks for fatal errors while leaving them out for warnings. By default the stack is printed
+// regardless of the level.
+func StackMinLevel(level Level) TextOption {
+	return func(f *textFormatter) {
+		f.stackMinLevel = level
+	}
+}
+
+// StackHeadTail abbreviates deep stacks to their first head (innermost) and last tail (outermost) frames, with a
+// "... (N frames omitted) ..." line in place of the frames in between. This keeps both where the error was
+// created and the entry point, while dropping the noisy middle. Stacks with no more than head+tail frames are
+// printed in full. It only affects [StackFull]. By default every frame is printed.
+func StackHeadTail(head, tail int) TextOption {
+	return func(f *textFormatter) {
+		f.stackHead, f.stackTail = max(head, 0), max(tail, 0)
+	}
+}
+
+// StackFirst prints the stacktrace before the wrapped error and the details instead of after them, so that it is
+// read first (as with Java stacktraces). By default the stacktrace comes last.
+func StackFirst(on bool) TextOption {
+	return func(f *textFormatter) {
+		f.stackFirst = on
+	}
+}
+
+// StackVerbosity selects how much of the stacktrace the text formatter prints. See [StackMode].
+type StackVerbosity int
+
+const (
+	// StackFull prints every frame of the stacktrace. This is the default.
+	StackFull StackVerbosity = iota
+	// StackTop only prints the location of the innermost frame, e.g. "At [file.go:12]".
+	StackTop
+	// StackNone leaves the stacktrace out entirely.
+	StackNone
+)
+
+// StackMode sets how much of the stacktrace is printed: all of it ([StackFull]), only the location where
+// the error was created ([StackTop]), or nothing at all ([StackNone]). By default it is [StackFull].
+func StackMode(mode StackVerbosity) TextOption {
+	return func(f *textFormatter) {
+		f.stackMode = mode
+	}
+}
+
+// FullFunctionNames controls whether stack frames show the fully-qualified function name including its import
+// path (e.g. "github.com/acme/x/y.Foo"). When it is off, only the last element of the package path is kept
+// (e.g. "y.Foo"). By default it is on.
+func FullFunctionNames(on bool) TextOption {
+	return func(f *textFormatter) {
+		f.shortFunctionNames = !on
+	}
+}
+
+// FullFilePaths controls whether stack frame locations show the whole file path instead of only the base name
+// of the file. By default it is off.
+func FullFilePaths(on bool) TextOption {
+	return func(f *textFormatter) {
+		f.fullFilePaths = on
+	}
+}
+
+// ShowFields adds a "fields: key=value ..." line to the output holding the structured fields of the error (see
+// [Record.With]), sorted by key. Fields whose value is an error are rendered as that error's chain of messages
+// (see [ChainString]). By default it is off.
+func ShowFields(on bool) TextOption {
+	return func(f *textFormatter) {
+		f.showFields = on
+	}
+}
+
 // textFormatter is the default [Formatter] used in the errors.
-type textFormatter struct{}
+type textFormatter struct {
+	alignFrames          bool
+	showFields           bool
+	maxFields            int
+	showHelpURL          bool
+	shortFunctionNames   bool
+	fullFilePaths        bool
+	showBuildInfo        bool
+	showHostInfo         bool
+	showSummary          bool
+	hidePackageFrames    bool
+	reverseDetails       bool
+	hideDetailLocations  bool
+	dedupeWrappedMessage bool
+	dedupeStackFrames    bool
+	detailOffsets        bool
+	minDetailLevel       Level
+	stackMinLevel        Level
+	stackFirst           bool
+	stackHead            int
+	stackTail            int
+	stackMode            StackVerbosity
+	// nested is set while formatting an [Error] wrapped by the one being formatted, so that the parts which
+	// describe the whole chain (the summary, build info, and host info) are only written once, at the top.
+	nested bool
+}
 
-// Format implements the [Formatter] interface.
+// Format implements the [Formatter] interface. It supports the following verbs:
+//
+//   - %v: the full output with the long form of the stack frames (function name and location)
+//   - %s: the full output with the short form of the stack frames (location only)
+//   - %q: the output without the stacktrace, as a double-quoted Go string literal
+//   - %j: the output of the [JSONFormatter]
+//
+// Any other verb is treated as %v.
 func (f textFormatter) Format(e *Error, s fmt.State, verb rune) {
+	switch verb {
+	case 'j':
+		jsonFormatter{}.Format(e, s, 'v')
+		return
+	case 'q':
+		f.formatQuoted(e, s)
+		return
+	case 's', 'v':
+	default:
+		verb = 'v'
+	}
+	if f.showSummary && !f.nested {
+		f.formatSummary(e, s)
+	}
+	if f.isTrivial(e) {
+		f.formatTrivial(e, s)
+		return
+	}
+	if f.stackFirst {
+		f.formatStacksFirst(e, s, verb)
+	}
 	f.formatWrappedError(e, s, verb)
 	f.formatDetails(e, s, verb)
-	f.formatStack(e.Stack, s, verb)
+	f.formatHelpURL(e, s)
+	f.formatHint(e, s)
+	f.formatOp(e, s)
+	f.formatFields(e, s)
+	f.formatElapsed(e, s)
+	if !f.nested {
+		f.formatBuildInfo(s)
+		f.formatHostInfo(s)
+	}
+	if !f.stackFirst {
+		f.formatStacks(e, s, verb)
+	}
+}
+
+// formatStacks writes the stack (and captured stack) of the error, if its level calls for it.
+func (f textFormatter) formatStacks(e *Error, s fmt.State, verb rune) {
+	if f.stackMinLevel == LevelUnset || LevelOf(e) >= f.stackMinLevel {
+		f.formatStack(e.Stack, s, verb, DefaultStackHeader, "At", f.sharedFrames(e))
+		f.formatStack(e.CapturedAt, s, verb, CapturedAtStackHeader, "Continued from", 0)
+	}
+}
+
+// sharedFrames returns how many of the outermost frames of the stack of e were already printed as part of the
+// stack of the error it wraps, if [DedupeStackFrames] is on.
+func (f textFormatter) sharedFrames(e *Error) int {
+	if !f.dedupeStackFrames || f.stackFirst || chainContains(e.Wraps, e) {
+		return 0
+	}
+	wrapped, ok := e.Wraps.(*Error)
+	if !ok {
+		return 0
+	}
+	if _, ok := wrapRenderer(wrapped); ok {
+		return 0
+	}
+	if f.stackMinLevel != LevelUnset && LevelOf(wrapped) < f.stackMinLevel {
+		return 0
+	}
+	return e.Stack.CommonPrefix(wrapped.Stack)
+}
+
+// formatStacksFirst writes the stacks at the start of the output, separated from the rest by a blank line.
+func (f textFormatter) formatStacksFirst(e *Error, s fmt.State, verb rune) {
+	sb := &strings.Builder{}
+	f.formatStacks(e, &writerState{w: sb, plus: s.Flag('+')}, verb)
+	if stacks := strings.TrimLeft(sb.String(), "\n"); stacks != "" {
+		_, _ = io.WriteString(s, stacks+"\n\n")
+	}
+}
+
+// formatQuoted writes the error without its stacktrace as a quoted string.
+func (f textFormatter) formatQuoted(e *Error, s fmt.State) {
+	compact := f
+	compact.stackMode = StackNone
+	sb := &strings.Builder{}
+	compact.Format(e, &writerState{w: sb}, 'v')
+	_, _ = io.WriteString(s, strconv.Quote(sb.String()))
+}
+
+func (f textFormatter) formatSummary(e *Error, s fmt.State) {
+	_, _ = fmt.Fprintf(s, "summary: %v", e.Summary())
+	if root := RootCause(e); root != e && !chainContains(e.Wraps, e) {
+		_, _ = fmt.Fprintf(s, " (caused by: %v)", message(root))
+	}
+	_, _ = io.WriteString(s, "\n")
+}
+
+// isTrivial reports whether the error has nothing to print beyond (at most) a single detail.
+func (f textFormatter) isTrivial(e *Error) bool {
+	if e.Wraps != nil || len(e.Details) > 1 || len(e.Stack.Frames) > 0 || len(e.CapturedAt.Frames) > 0 ||
+		e.Elapsed != 0 || e.Hint != "" || e.Op != "" || f.detailOffsets || (!f.nested && (f.showBuildInfo ||
+		f.showHostInfo)) || f.showHelpURL || (f.showFields && len(e.Fields) > 0) {
+		return false
+	}
+	return f.minDetailLevel == LevelUnset &&
+		(len(e.Details) == 0 || (len(e.Details[0].Vars) == 0 && e.Details[0].Location == Frame{}))
+}
+
+// formatTrivial writes the same output as formatDetails would for a trivial error, without the extra work.
+func (f textFormatter) formatTrivial(e *Error, s fmt.State) {
+	msg := ""
+	if len(e.Details) == 1 {
+		msg = e.Details[0].Message
+	}
+	_, _ = io.WriteString(s, "["+msg+"]")
 }
 
 func (f textFormatter) formatWrappedError(e *Error, s fmt.State, verb rune) {
-	if e.Wraps != nil {
-		formattable, ok := e.Wraps.(fmt.Formatter)
-		if ok {
-			formattable.Format(s, verb)
-			_, _ = io.WriteString(s, "\n")
-		} else {
-			_, _ = fmt.Fprintf(s, "%s\n", e.Wraps.Error())
+	if chainContains(e.Wraps, e) {
+		_, _ = io.WriteString(s, cycleDetected+"\n")
+		return
+	}
+	if e.Wraps == nil || f.isDuplicateWrap(e) {
+		return
+	}
+	if render, ok := wrapRenderer(e.Wraps); ok {
+		_, _ = io.WriteString(s, render(e.Wraps)+"\n")
+		return
+	}
+	switch wrapped := e.Wraps.(type) {
+	case *Error:
+		// Nested errors are rendered with this formatter (and its options) so the whole chain looks consistent.
+		nested := f
+		nested.nested = true
+		nested.Format(wrapped, s, verb)
+		_, _ = io.WriteString(s, "\n")
+	case fmt.Formatter:
+		wrapped.Format(s, verb)
+		_, _ = io.WriteString(s, "\n")
+	default:
+		_, _ = fmt.Fprintf(s, "%s\n", formattedString(wrapped))
+	}
+}
+
+// isDuplicateWrap reports whether the wrapped error should be left out because one of the details already
+// contains its message. Wrapped [Error]s are never left out, since they carry more than a message.
+func (f textFormatter) isDuplicateWrap(e *Error) bool {
+	if !f.dedupeWrappedMessage {
+		return false
+	}
+	if _, ok := e.Wraps.(*Error); ok {
+		return false
+	}
+	msg := formattedString(e.Wraps)
+	for _, detail := range e.Details {
+		if strings.Contains(detail.Message, msg) {
+			return true
+		}
+	}
+	return false
+}
+
+// showDetail reports whether the detail passes the minimum level. Details without a level are always shown.
+func (f textFormatter) showDetail(detail Detail) bool {
+	return detail.Level == LevelUnset || detail.Level >= f.minDetailLevel
+}
+
+// formatVarDetail renders a detail that carries vars as "[file:line] (key=value ...) message".
+func (f textFormatter) formatVarDetail(detail Detail) string {
+	sb := &strings.Builder{}
+	if !f.hideDetailLocations {
+		_, _ = fmt.Fprintf(sb, "[%v] ", f.location(detail.Location))
+	}
+	sb.WriteString("(")
+	for i, v := range detail.Vars {
+		if i > 0 {
+			sb.WriteString(" ")
 		}
+		_, _ = fmt.Fprintf(sb, "%v=%v", v.Key, v.Value)
+	}
+	sb.WriteString(")")
+	if detail.Message != "" && detail.annotated {
+		sb.WriteString(" " + shortFunctionName(detail.Location.Function) + ": " + detail.Message)
+	} else if detail.Message != "" {
+		sb.WriteString(" " + detail.Message)
 	}
+	return sb.String()
 }
 
 func (f textFormatter) formatDetails(e *Error, s fmt.State, verb rune) {
-	_, _ = fmt.Fprintf(s, "%v", e.Details)
+	var start time.Time
+	if f.detailOffsets {
+		start, _, _ = detailTimes(e)
+	}
+	// Empty messages are skipped so they don't leave a dangling separator behind.
+	details := make([]string, 0, len(e.Details))
+	collect := func(detail Detail) bool {
+		text := ""
+		if len(detail.Vars) > 0 && f.showDetail(detail) {
+			text = f.formatVarDetail(detail)
+		} else if detail.Message != "" && f.showDetail(detail) {
+			text = detail.Message
+			if detail.Location.Function != "" {
+				text = shortFunctionName(detail.Location.Function) + ": " + text
+			}
+		} else {
+			return true
+		}
+		if f.detailOffsets && !detail.Time.IsZero() {
+			text = "[+" + detail.Time.Sub(start).Round(time.Millisecond).String() + "] " + text
+		}
+		details = append(details, text)
+		return true
+	}
+	if f.reverseDetails {
+		e.EachDetailReverse(collect)
+	} else {
+		for _, detail := range e.Details {
+			collect(detail)
+		}
+	}
+	_, _ = fmt.Fprintf(s, "%v", details)
 }
 
-func (f textFormatter) formatFrame(frame Frame, s fmt.State, verb rune) {
-	fileParts := strings.Split(frame.File, "/")
-	switch verb {
-	case 's':
-		_, _ = fmt.Fprintf(s, "[%v:%v]", fileParts[len(fileParts)-1], frame.Line)
-	default:
-		_, _ = fmt.Fprintf(s, "%v [%v:%v]", frame.Function, fileParts[len(fileParts)-1], frame.Line)
+func (f textFormatter) formatHelpURL(e *Error, s fmt.State) {
+	if !f.showHelpURL {
+		return
+	}
+	if url, ok := HelpURL(e); ok {
+		_, _ = fmt.Fprintf(s, " (see %v)", url)
+	}
+}
+
+func (f textFormatter) formatHint(e *Error, s fmt.State) {
+	if e.Hint != "" {
+		_, _ = io.WriteString(s, "\nhint: "+e.Hint)
+	}
+}
+
+func (f textFormatter) formatOp(e *Error, s fmt.State) {
+	if e.Op != "" {
+		_, _ = io.WriteString(s, "\nop="+e.Op)
 	}
 }
 
-func (f textFormatter) formatStack(stack Stack, s fmt.State, verb rune) {
-	if len(stack.Frames) == 0 {
+func (f textFormatter) formatFields(e *Error, s fmt.State) {
+	if !f.showFields || len(e.Fields) == 0 {
 		return
 	}
-	_, _ = io.WriteString(s, "\n\nWith Stacktrace:\n")
-	for i, frame := range stack.Frames {
-		f.formatFrame(frame, s, verb)
+	keys := make([]string, 0, len(e.Fields))
+	for key := range e.Fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	_, _ = io.WriteString(s, "\nfields:")
+	omitted := 0
+	if f.maxFields > 0 && len(keys) > f.maxFields {
+		keys, omitted = keys[:f.maxFields], len(keys)-f.maxFields
+	}
+	for _, key := range keys {
+		_, _ = fmt.Fprintf(s, " %v=%v", key, fieldText(e.Fields[key]))
+	}
+	if omitted > 0 {
+		_, _ = fmt.Fprintf(s, " (+%v more fields)", omitted)
+	}
+}
+
+// fieldText returns the value of a field as it should be shown by the text formatter. Durations and [Bytes] are
+// spelled out in a human-friendly form rather than as raw integers.
+func fieldText(value any) any {
+	value = resolveField(value)
+	switch v := value.(type) {
+	case error:
+		return ChainString(v, "")
+	case time.Duration:
+		return v.String()
+	case Bytes:
+		return v.String()
+	}
+	return value
+}
+
+func (f textFormatter) formatElapsed(e *Error, s fmt.State) {
+	if e.Elapsed != 0 {
+		_, _ = io.WriteString(s, "\n"+formatElapsed(e.Elapsed))
+	}
+}
+
+func (f textFormatter) formatBuildInfo(s fmt.State) {
+	if !f.showBuildInfo {
+		return
+	}
+	if version, commit := BuildInfo(); version != "" || commit != "" {
+		_, _ = fmt.Fprintf(s, "\nbuild=%v", version)
+		if commit != "" {
+			_, _ = fmt.Fprintf(s, " (%v)", commit)
+		}
+	}
+	revision, commitTime, modified := VCSInfo()
+	if revision == "" {
+		return
+	}
+	_, _ = fmt.Fprintf(s, "\nrevision=%v", revision)
+	extra := []string{}
+	if commitTime != "" {
+		extra = append(extra, commitTime)
+	}
+	if modified {
+		extra = append(extra, "modified")
+	}
+	if len(extra) > 0 {
+		_, _ = fmt.Fprintf(s, " (%v)", strings.Join(extra, ", "))
+	}
+}
+
+func (f textFormatter) formatHostInfo(s fmt.State) {
+	if !f.showHostInfo {
+		return
+	}
+	if host := HostInfo(); host != "" {
+		_, _ = fmt.Fprintf(s, "\nhost=%v", host)
+	}
+}
+
+// formatFrame writes the frame piece by piece rather than through fmt, since it runs once per frame and is the
+// bulk of the work when formatting an error.
+func (f textFormatter) formatFrame(frame Frame, s fmt.State, verb rune, width int) {
+	if verb != 's' {
+		name := f.function(frame)
+		_, _ = io.WriteString(s, name)
+		for pad := width - len(name); pad > 0; pad -= len(framePadding) {
+			_, _ = io.WriteString(s, framePadding[:min(pad, len(framePadding))])
+		}
+		_, _ = io.WriteString(s, " ")
+	}
+	_, _ = io.WriteString(s, "[")
+	_, _ = io.WriteString(s, f.location(frame))
+	_, _ = io.WriteString(s, "]")
+}
+
+// framePadding is used to align the frames when [AlignFrames] is on.
+const framePadding = "                                "
+
+// function returns the function name of the frame, trimmed down to the last package path element unless full
+// function names are enabled.
+func (f textFormatter) function(frame Frame) string {
+	if !f.shortFunctionNames {
+		return frame.Function
+	}
+	return trimImportPath(frame.Function)
+}
+
+// location returns the "file:line" part of the frame, using the base name of the file unless full file paths
+// are enabled.
+func (f textFormatter) location(frame Frame) string {
+	if deterministicStacks.Load() {
+		return fileBase(frame.File) + ":NN"
+	}
+	file := frame.File
+	if !f.fullFilePaths {
+		file = fileBase(frame.File)
+	}
+	return file + ":" + strconv.Itoa(frame.Line)
+}
+
+// functionWidth returns the length of the longest function name in the stack if frames should be aligned.
+func (f textFormatter) functionWidth(stack Stack) int {
+	if !f.alignFrames {
+		return 0
+	}
+	width := 0
+	for _, frame := range stack.Frames {
+		if name := f.function(frame); len(name) > width {
+			width = len(name)
+		}
+	}
+	return width
+}
+
+// formatStack writes the stack below header, or only its innermost frame after topLabel for [StackTop].
+func (f textFormatter) formatStack(stack Stack, s fmt.State, verb rune, header, topLabel string, shared int) {
+	if f.hidePackageFrames {
+		stack = trimPackageFrames(stack)
+	}
+	if len(stack.Frames) == 0 || f.stackMode == StackNone {
+		return
+	}
+	if f.stackMode == StackTop {
+		top, _ := stack.Top()
+		_, _ = fmt.Fprintf(s, "\n\n%v [%v]", topLabel, f.location(top))
+		return
+	}
+	if shared > 0 {
+		shared = min(shared, len(stack.Frames))
+		stack = Stack{Frames: stack.Frames[:len(stack.Frames)-shared]}
+	}
+	_, _ = io.WriteString(s, header)
+	width := f.functionWidth(stack)
+	omitStart, omitEnd := len(stack.Frames), len(stack.Frames)
+	if (f.stackHead > 0 || f.stackTail > 0) && f.stackHead+f.stackTail < len(stack.Frames) {
+		omitStart, omitEnd = f.stackHead, len(stack.Frames)-f.stackTail
+	}
+	for i := 0; i < len(stack.Frames); i++ {
+		if i == omitStart {
+			_, _ = fmt.Fprintf(s, "... (%v frames omitted) ...", omitEnd-omitStart)
+			i = omitEnd - 1
+		} else {
+			f.formatFrame(stack.Frames[i], s, verb, width)
+		}
 		if i == len(stack.Frames)-1 {
 			break
 		}
-		_, _ = io.WriteString(s, "\n")
+		_, _ = io.WriteString(s, DefaultFrameSeparator)
+	}
+	if shared > 0 {
+		if len(stack.Frames) > 0 {
+			_, _ = io.WriteString(s, DefaultFrameSeparator)
+		}
+		_, _ = io.WriteString(s, "... (same as above)")
+	}
+}
+
+// JSONFormatter returns a [Formatter] which writes the error out as a single JSON object. The wrapped error is
+// written as a string, while structured fields keep their native JSON types.
+func JSONFormatter() Formatter {
+	return jsonFormatter{}
+}
+
+// JSONOption configures the [Formatter] returned by [NewJSONFormatter].
+type JSONOption func(f *jsonFormatter)
+
+// NewJSONFormatter returns the JSON [Formatter] configured with the given options. Calling it without any
+// options gives the same result as [JSONFormatter].
+func NewJSONFormatter(opts ...JSONOption) Formatter {
+	f := jsonFormatter{}
+	for _, opt := range opts {
+		opt(&f)
+	}
+	return f
+}
+
+// JSONIncludeHost adds a "host" key holding the value from [SetHostInfo]. By default it is off.
+func JSONIncludeHost(on bool) JSONOption {
+	return func(f *jsonFormatter) {
+		f.includeHost = on
+	}
+}
+
+// JSONIncludeBuildInfo adds a "build" object holding the "version" and "commit" from [SetBuildInfo] and the
+// "revision", "commit_time", and "modified" values from [VCSInfo]. Values that aren't known are left out, and
+// so is the whole object if none are. By default it is off.
+func JSONIncludeBuildInfo(on bool) JSONOption {
+	return func(f *jsonFormatter) {
+		f.includeBuildInfo = on
+	}
+}
+
+// JSONIncludeHelpURL adds a "help_url" key holding the documentation URL registered for the [Kind] of the
+// error (see [RegisterHelpURL]), if there is one. By default it is off.
+func JSONIncludeHelpURL(on bool) JSONOption {
+	return func(f *jsonFormatter) {
+		f.includeHelpURL = on
+	}
+}
+
+// JSONMaxFields limits "fields" to the first n fields (by key) and adds a "fields_omitted" key holding the
+// number of fields that were left out. By default (or if n is zero or less) every field is written.
+func JSONMaxFields(n int) JSONOption {
+	return func(f *jsonFormatter) {
+		f.maxFields = n
+	}
+}
+
+// JSONOmitEmpty leaves "wraps", "stack", and "details" out of the output when the error doesn't wrap anything,
+// has no stack, or has no details, which keeps the JSON of trivial errors small. By default it is on; turning
+// it off always writes all three keys, as the JSON of schema versions before 4 did.
+func JSONOmitEmpty(on bool) JSONOption {
+	return func(f *jsonFormatter) {
+		f.keepEmpty = !on
+	}
+}
+
+// jsonFormatter writes errors as JSON objects.
+type jsonFormatter struct {
+	maxFields        int
+	includeHost      bool
+	includeBuildInfo bool
+	includeHelpURL   bool
+	keepEmpty        bool
+}
+
+type jsonError struct {
+	Version       int            `json:"version"`
+	Message       string         `json:"message"`
+	Wraps         string         `json:"wraps,omitempty"`
+	Stack         *jsonStack     `json:"stack,omitempty"`
+	CapturedAt    *jsonStack     `json:"captured_at,omitempty"`
+	Details       []string       `json:"details,omitempty"`
+	Fields        map[string]any `json:"fields,omitempty"`
+	FieldsOmitted int            `json:"fields_omitted,omitempty"`
+	Tags          []string       `json:"tags,omitempty"`
+	Host          string         `json:"host,omitempty"`
+	Build         *jsonBuild     `json:"build,omitempty"`
+	HelpURL       string         `json:"help_url,omitempty"`
+	Hint          string         `json:"hint,omitempty"`
+	Op            string         `json:"op,omitempty"`
+	Elapsed       string         `json:"elapsed,omitempty"`
+}
+
+// jsonErrorKeepEmpty is the same as jsonError, except that "wraps", "stack", and "details" are always written. A
+// *jsonError can be converted to it, since struct tags don't count for conversions.
+type jsonErrorKeepEmpty struct {
+	Version       int            `json:"version"`
+	Message       string         `json:"message"`
+	Wraps         string         `json:"wraps"`
+	Stack         *jsonStack     `json:"stack"`
+	CapturedAt    *jsonStack     `json:"captured_at,omitempty"`
+	Details       []string       `json:"details"`
+	Fields        map[string]any `json:"fields,omitempty"`
+	FieldsOmitted int            `json:"fields_omitted,omitempty"`
+	Tags          []string       `json:"tags,omitempty"`
+	Host          string         `json:"host,omitempty"`
+	Build         *jsonBuild     `json:"build,omitempty"`
+	HelpURL       string         `json:"help_url,omitempty"`
+	Hint          string         `json:"hint,omitempty"`
+	Op            string         `json:"op,omitempty"`
+	Elapsed       string         `json:"elapsed,omitempty"`
+}
+
+type jsonBuild struct {
+	Version    string `json:"version,omitempty"`
+	Commit     string `json:"commit,omitempty"`
+	Revision   string `json:"revision,omitempty"`
+	CommitTime string `json:"commit_time,omitempty"`
+	Modified   bool   `json:"modified,omitempty"`
+}
+
+// newJSONBuild returns the build information for the "build" key, or nil if nothing is known.
+func newJSONBuild() *jsonBuild {
+	build := jsonBuild{}
+	build.Version, build.Commit = BuildInfo()
+	build.Revision, build.CommitTime, build.Modified = VCSInfo()
+	if build == (jsonBuild{}) {
+		return nil
+	}
+	return &build
+}
+
+type jsonStack struct {
+	Frames []jsonFrame `json:"frames"`
+}
+
+type jsonFrame struct {
+	Frame
+	FileBase string `json:"file_base"`
+}
+
+func newJSONStack(stack Stack) jsonStack {
+	out := jsonStack{Frames: make([]jsonFrame, 0, len(stack.Frames))}
+	deterministic := deterministicStacks.Load()
+	for _, frame := range stack.Frames {
+		if deterministic {
+			frame.Line, frame.File = 0, fileBase(frame.File)
+		}
+		out.Frames = append(out.Frames, jsonFrame{Frame: frame, FileBase: fileBase(frame.File)})
+	}
+	return out
+}
+
+// Format implements the [Formatter] interface.
+func (f jsonFormatter) Format(e *Error, s fmt.State, verb rune) {
+	out := f.newJSONError(e)
+	out.Wraps = jsonWraps(e)
+	_, _ = s.Write(marshalJSONError(f.marshaled(&out), &out, e.Fields))
+}
+
+// marshaled returns what to marshal for out, taking [JSONOmitEmpty] into account.
+func (f jsonFormatter) marshaled(out *jsonError) any {
+	if f.keepEmpty {
+		return (*jsonErrorKeepEmpty)(out)
+	}
+	return out
+}
+
+// jsonWraps returns the message of the error wrapped by e.
+func jsonWraps(e *Error) string {
+	if chainContains(e.Wraps, e) {
+		return cycleDetected
+	} else if e.Wraps != nil {
+		return formattedString(e.Wraps)
+	}
+	return ""
+}
+
+// newJSONError converts everything but the wrapped error into its JSON representation.
+func (f jsonFormatter) newJSONError(e *Error) jsonError {
+	visited := visitSet{}
+	visited.add(e)
+	return f.newJSONErrorVisited(e, &visited)
+}
+
+// newJSONErrorVisited is the same as newJSONError, where visited holds the errors that are already being
+// converted further up, so that errors stored in each other's fields can't recurse forever.
+func (f jsonFormatter) newJSONErrorVisited(e *Error, visited *visitSet) jsonError {
+	out := jsonError{
+		Version: JSONSchemaVersion,
+		Message: e.Summary(),
+		Fields:  f.jsonFields(e.Fields, visited),
+		Tags:    e.Tags,
+	}
+	if f.maxFields > 0 && len(out.Fields) > f.maxFields {
+		out.Fields, out.FieldsOmitted = firstFields(out.Fields, f.maxFields), len(out.Fields)-f.maxFields
+	}
+	if len(e.Stack.Frames) > 0 || f.keepEmpty {
+		stack := newJSONStack(e.Stack)
+		out.Stack = &stack
+	}
+	if len(e.CapturedAt.Frames) > 0 {
+		capturedAt := newJSONStack(e.CapturedAt)
+		out.CapturedAt = &capturedAt
+	}
+	for _, detail := range e.Details {
+		out.Details = append(out.Details, detail.Message)
+	}
+	if f.includeHost {
+		out.Host = HostInfo()
+	}
+	if f.includeBuildInfo {
+		out.Build = newJSONBuild()
+	}
+	if f.includeHelpURL {
+		out.HelpURL, _ = HelpURL(e)
+	}
+	// The wrapped error is only written as a string, so the hint and operation come from the whole chain.
+	out.Hint, _ = Hint(e)
+	out.Op, _ = OpOf(e)
+	if e.Elapsed != 0 {
+		out.Elapsed = formatElapsed(e.Elapsed)
+	}
+	return out
+}
+
+// firstFields returns a copy of fields that only holds the first n keys in sorted order.
+func firstFields(fields map[string]any, n int) map[string]any {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	out := make(map[string]any, n)
+	for _, key := range keys[:n] {
+		out[key] = fields[key]
+	}
+	return out
+}
+
+// jsonFields returns fields with any error values replaced by something that marshals sensibly: the structured
+// form of an [Error], and the message of any other error. The map is only copied if it holds an error.
+func (f jsonFormatter) jsonFields(fields map[string]any, visited *visitSet) map[string]any {
+	var out map[string]any
+	for key, value := range fields {
+		err, ok := resolveField(value).(error)
+		if !ok {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]any, len(fields))
+			for k, v := range fields {
+				out[k] = v
+			}
+		}
+		out[key] = f.jsonFieldError(err, visited)
+	}
+	if out == nil {
+		return fields
+	}
+	return out
+}
+
+func (f jsonFormatter) jsonFieldError(err error, visited *visitSet) any {
+	e, ok := err.(*Error)
+	if !ok {
+		return formattedString(err)
+	}
+	if !visited.add(e) {
+		return cycleDetected
+	}
+	out := f.newJSONErrorVisited(e, visited)
+	out.Wraps = jsonWraps(e)
+	return f.marshaled(&out)
+}
+
+// marshalJSONError marshals v, which is or contains out. If some field value can't be marshaled, it falls back
+// to the string form of every field.
+func marshalJSONError(v any, out *jsonError, fields map[string]any) []byte {
+	data, err := json.Marshal(v)
+	if err != nil {
+		// Only the keys that made it into the output are kept, so any cap on the fields still holds.
+		stringFields := make(map[string]any, len(out.Fields))
+		for key := range out.Fields {
+			stringFields[key] = fmt.Sprintf("%v", fields[key])
+		}
+		out.Fields = stringFields
+		data, _ = json.Marshal(v)
+	}
+	return data
+}
+
+// NDJSONFormatter returns a [Formatter] which writes one JSON object per [Error] in the wrap chain, from the
+// outermost to the innermost, separated by newlines. Each object has the same shape as the output of the
+// [JSONFormatter] plus a "depth" key (starting at 0 for the outermost error). An object only has "wraps" set
+// when the error it wraps isn't written as its own line. Errors from other packages that wrap another [Error]
+// (e.g. from fmt.Errorf) get a line of their own with only "depth", "version", and the text they add as
+// "message". Joined errors are written as a single line.
+func NDJSONFormatter() Formatter {
+	return ndjsonFormatter{}
+}
+
+// ndjsonFormatter writes every link of the chain as its own JSON object.
+type ndjsonFormatter struct {
+	json jsonFormatter
+}
+
+type ndjsonLink struct {
+	Depth int `json:"depth"`
+	jsonError
+}
+
+// Format implements the [Formatter] interface.
+func (f ndjsonFormatter) Format(e *Error, s fmt.State, verb rune) {
+	visited := visitSet{}
+	var err error = e
+	for depth := 0; err != nil; depth++ {
+		if depth > 0 {
+			_, _ = io.WriteString(s, "\n")
+		}
+		e, ok := err.(*Error)
+		if !ok {
+			// ndjsonOwnLine guarantees that this wrapper leads to another Error.
+			inner := errors.Unwrap(err)
+			data, _ := json.Marshal(ndjsonLink{Depth: depth, jsonError: jsonError{
+				Version: JSONSchemaVersion,
+				Message: wrapperMessage(err, inner),
+			}})
+			_, _ = s.Write(data)
+			err = inner
+			continue
+		}
+		if !visited.add(e) {
+			data, _ := json.Marshal(ndjsonLink{Depth: depth, jsonError: jsonError{
+				Version: JSONSchemaVersion,
+				Wraps:   cycleDetected,
+			}})
+			_, _ = s.Write(data)
+			return
+		}
+		link := ndjsonLink{Depth: depth, jsonError: f.json.newJSONError(e)}
+		err = e.Wraps
+		if err != nil && !ndjsonOwnLine(err) {
+			link.Wraps = formattedString(err)
+			err = nil
+		}
+		_, _ = s.Write(marshalJSONError(&link, &link.jsonError, e.Fields))
+	}
+}
+
+// ndjsonOwnLine reports whether err is written as its own line by the [NDJSONFormatter], which is the case for
+// an [Error] and for errors that wrap one without going through a join.
+func ndjsonOwnLine(err error) bool {
+	if _, ok := err.(*Error); ok {
+		return true
+	}
+	_, ok := nearestLinear(errors.Unwrap(err))
+	return ok
+}
+
+// wrapperMessage returns the text that err adds in front of the message of the error it wraps (e.g. "mid" for
+// fmt.Errorf("mid: %w", inner)), or the whole message of err if it doesn't have that layout.
+func wrapperMessage(err, inner error) string {
+	msg := err.Error()
+	if prefix, ok := strings.CutSuffix(msg, inner.Error()); ok {
+		return strings.TrimSuffix(strings.TrimSpace(prefix), ":")
+	}
+	return msg
+}
+
+// SyslogFormatter returns a [Formatter] that writes the error as a single line, which is what syslog (and
+// most other line-based log collectors) expect. It is the same as the text formatter with [StackMode] set to
+// [StackTop], except that line breaks are replaced by spaces. Use [SyslogSeverity] to pick the priority.
+func SyslogFormatter() Formatter {
+	return syslogFormatter{text: textFormatter{stackMode: StackTop}}
+}
+
+// syslogFormatter writes the output of the text formatter on a single line.
+type syslogFormatter struct {
+	text textFormatter
+}
+
+// Format implements the [Formatter] interface.
+func (f syslogFormatter) Format(e *Error, s fmt.State, verb rune) {
+	sb := &strings.Builder{}
+	f.text.Format(e, &writerState{w: sb}, verb)
+	// Only line breaks are replaced, so tabs and runs of spaces in messages and fields are kept as they are.
+	lines := strings.FieldsFunc(sb.String(), func(r rune) bool { return r == '\n' || r == '\r' })
+	_, _ = io.WriteString(s, strings.Join(lines, " "))
+}
+
+// DetailsFormatter returns a [Formatter] that only writes the detail messages of the error, joined by the
+// given separator (e.g. ": " or "\n"). The wrapped error and the stacktrace are left out entirely, which is
+// useful when the cause is logged separately and only the trail of annotations is wanted.
+func DetailsFormatter(separator string) Formatter {
+	return detailsFormatter{separator: separator}
+}
+
+// detailsFormatter writes nothing but the details of the error.
+type detailsFormatter struct {
+	separator string
+}
+
+// Format implements the [Formatter] interface.
+func (f detailsFormatter) Format(e *Error, s fmt.State, verb rune) {
+	first := true
+	for _, detail := range e.Details {
+		if detail.Message == "" {
+			continue
+		}
+		if !first {
+			_, _ = io.WriteString(s, f.separator)
+		}
+		_, _ = io.WriteString(s, detail.Message)
+		first = false
 	}
 }
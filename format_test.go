@@ -1,7 +1,10 @@
 package evs
 
 import (
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
 )
 
@@ -15,7 +18,7 @@ func getTestError() Error {
 				Function: "FunctionName",
 			}},
 		},
-		Details: []string{"oh no!"},
+		Details: []Detail{{Message: "oh no!"}},
 		f:       textFormatter{},
 	}
 }
@@ -54,3 +57,614 @@ func TestTextFormatterNoStackOrWrapped(t *testing.T) {
 		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
 	}
 }
+
+func TestTextFormatterEmptyDetail(t *testing.T) {
+	err := getTestError()
+	err.Wraps = nil
+	err.Stack = Stack{}
+	err.Details = []Detail{{Message: "oh no!"}, {Message: ""}}
+	result := err.Error()
+	expect := `[oh no!]`
+	if result != expect {
+		t.Fatalf("Expected\n%q\nbut got\n%q", expect, result)
+	}
+}
+
+func TestTextFormatterAlignFrames(t *testing.T) {
+	err := getTestError()
+	err.Wraps = nil
+	err.Stack.Frames = append(err.Stack.Frames, Frame{Line: 12, File: "other.go", Function: "Fn"})
+	err.f = NewTextFormatter(AlignFrames(true))
+	result := err.Error()
+	expect := `[oh no!]
+
+With Stacktrace:
+FunctionName [file.go:0]
+Fn           [other.go:12]`
+	if result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+}
+
+func TestTextFormatterDeterministicStacks(t *testing.T) {
+	SetDeterministicStacks(true)
+	defer SetDeterministicStacks(false)
+	err := getTestError()
+	err.Stack.Frames[0].File = "/home/user/project/file.go"
+	err.Stack.Frames[0].Line = 42
+	expect := `bad error
+[oh no!]
+
+With Stacktrace:
+FunctionName [file.go:NN]`
+	for _, f := range []Formatter{TextFormatter(), NewTextFormatter(FullFilePaths(true))} {
+		err.f = f
+		if result := err.Error(); result != expect {
+			t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+		}
+	}
+}
+
+func TestJSONFormatterDeterministicStacks(t *testing.T) {
+	SetDeterministicStacks(true)
+	defer SetDeterministicStacks(false)
+	err := getTestError()
+	err.f = JSONFormatter()
+	err.Stack.Frames[0].File = "/home/user/project/file.go"
+	err.Stack.Frames[0].Line = 42
+	result := err.Error()
+	expect := `{"version":5,"message":"oh no!","wraps":"bad error","stack":{"frames":[{"line":0,"file":"file.go","function":"FunctionName","file_base":"file.go"}]},"details":["oh no!"]}`
+	if result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+}
+
+func TestJSONFormatter(t *testing.T) {
+	err := getTestError()
+	err.f = JSONFormatter()
+	err.Fields = map[string]any{"id": 42, "retry": true}
+	result := err.Error()
+	expect := `{"version":5,"message":"oh no!","wraps":"bad error","stack":{"frames":[{"line":0,"file":"file.go","function":"FunctionName","file_base":"file.go"}]},"details":["oh no!"],"fields":{"id":42,"retry":true}}`
+	if result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+}
+
+func TestJSONFormatterOmitEmpty(t *testing.T) {
+	err := &Error{f: JSONFormatter()}
+	expect := `{"version":5,"message":""}`
+	if result := err.Error(); result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+	err.f = NewJSONFormatter(JSONOmitEmpty(false))
+	expect = `{"version":5,"message":"","wraps":"","stack":{"frames":[]},"details":null}`
+	if result := err.Error(); result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+}
+
+func TestJSONFormatterUnsupportedField(t *testing.T) {
+	err := getTestError()
+	err.f = JSONFormatter()
+	err.Stack = Stack{}
+	err.Fields = map[string]any{"id": 42, "ch": make(chan int)}
+	result := err.Error()
+	if !strings.Contains(result, `"id":"42"`) {
+		t.Fatalf("expected fields to fall back to strings but got\n%v", result)
+	}
+}
+
+func TestTextFormatterShowBuildInfo(t *testing.T) {
+	setTestVCSInfo(t, "", "", false)
+	version, commit := BuildInfo()
+	SetBuildInfo("v1.2.3", "abc123")
+	defer SetBuildInfo(version, commit)
+	err := getTestError()
+	err.Stack = Stack{}
+	err.f = NewTextFormatter(ShowBuildInfo(true))
+	result := err.Error()
+	expect := `bad error
+[oh no!]
+build=v1.2.3 (abc123)`
+	if result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+	setTestVCSInfo(t, "def456", "2023-09-01T12:00:00Z", true)
+	expect += "\nrevision=def456 (2023-09-01T12:00:00Z, modified)"
+	if result := err.Error(); result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+}
+
+func TestJSONIncludeBuildInfo(t *testing.T) {
+	setTestVCSInfo(t, "def456", "2023-09-01T12:00:00Z", true)
+	version, commit := BuildInfo()
+	SetBuildInfo("v1.2.3", "")
+	defer SetBuildInfo(version, commit)
+	err := getTestError()
+	err.Stack = Stack{}
+	err.f = NewJSONFormatter(JSONIncludeBuildInfo(true))
+	expect := `{"version":5,"message":"oh no!","wraps":"bad error","details":["oh no!"],"build":{"version":"v1.2.3","revision":"def456","commit_time":"2023-09-01T12:00:00Z","modified":true}}`
+	if result := err.Error(); result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+	setTestVCSInfo(t, "", "", false)
+	SetBuildInfo("", "")
+	if result := err.Error(); strings.Contains(result, `"build"`) {
+		t.Fatalf("expected no build object without build info but got %v", result)
+	}
+}
+
+func TestJSONFormatterTags(t *testing.T) {
+	err := getTestError()
+	err.f = JSONFormatter()
+	err.Stack = Stack{}
+	err.Tags = []string{"transient", "billing"}
+	result := err.Error()
+	if !strings.Contains(result, `"tags":["transient","billing"]`) {
+		t.Fatalf("expected tags in output but got\n%v", result)
+	}
+}
+
+func TestTextFormatterHidePackageFrames(t *testing.T) {
+	err := getTestError()
+	err.Wraps = nil
+	err.Stack.Frames = append([]Frame{
+		{Line: 10, File: "record.go", Function: "github.com/thenorthnate/evs.New"},
+		{Line: 20, File: "error.go", Function: "github.com/thenorthnate/evs.newError"},
+	}, err.Stack.Frames...)
+	err.f = NewTextFormatter(HidePackageFrames(true))
+	result := err.Error()
+	expect := `[oh no!]
+
+With Stacktrace:
+FunctionName [file.go:0]`
+	if result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+}
+
+func TestTextFormatterReverseDetails(t *testing.T) {
+	err := getTestError()
+	err.Wraps = nil
+	err.Stack = Stack{}
+	err.Details = []Detail{{Message: "first"}, {Message: "second"}, {Message: "third"}}
+	err.f = NewTextFormatter(ReverseDetails(true))
+	result := err.Error()
+	expect := `[third second first]`
+	if result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+}
+
+func BenchmarkTextFormatterTrivial(b *testing.B) {
+	err := getTestError()
+	err.Wraps = nil
+	err.Stack = Stack{}
+	err.f = TextFormatter()
+	// DetailOffsets doesn't change the output of details without timestamps, but it keeps the formatter off the
+	// fast path, so "general" is the baseline that the fast path is compared against.
+	general := err
+	general.f = NewTextFormatter(DetailOffsets(true))
+	if err.Error() != general.Error() {
+		b.Fatalf("expected the same output but got %q and %q", err.Error(), general.Error())
+	}
+	for _, bench := range []struct {
+		name string
+		err  *Error
+	}{{"fast", &err}, {"general", &general}} {
+		b.Run(bench.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				_ = bench.err.Error()
+			}
+		})
+	}
+}
+
+func TestJSONFormatterRoundTrip(t *testing.T) {
+	err := getTestError()
+	err.f = JSONFormatter()
+	err.Stack.Frames[0].File = "/home/user/project/file.go"
+	result := jsonError{}
+	if jsonErr := json.Unmarshal([]byte(err.Error()), &result); jsonErr != nil {
+		t.Fatalf("failed to unmarshal output: %v", jsonErr)
+	}
+	if result.Version != JSONSchemaVersion {
+		t.Fatalf("expected version %v but got %v", JSONSchemaVersion, result.Version)
+	}
+	if len(result.Stack.Frames) != 1 {
+		t.Fatalf("expected 1 frame but got %v", len(result.Stack.Frames))
+	}
+	frame := result.Stack.Frames[0]
+	if frame.Frame != err.Stack.Frames[0] {
+		t.Fatalf("expected frame %v but got %v", err.Stack.Frames[0], frame.Frame)
+	}
+	if frame.FileBase != "file.go" {
+		t.Fatalf("expected file_base file.go but got %v", frame.FileBase)
+	}
+}
+
+func TestTextFormatterMinDetailLevel(t *testing.T) {
+	err := getTestError()
+	err.Wraps = nil
+	err.Stack = Stack{}
+	err.Details = []Detail{
+		{Message: "query failed"},
+		{Message: "cache miss", Level: LevelDebug},
+		{Message: "retrying", Level: LevelWarn},
+	}
+	err.f = NewTextFormatter(MinDetailLevel(LevelInfo))
+	result := err.Error()
+	expect := `[query failed retrying]`
+	if result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+	err.f = TextFormatter()
+	result = err.Error()
+	expect = `[query failed cache miss retrying]`
+	if result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+}
+
+func TestTextFormatterVars(t *testing.T) {
+	err := getTestError()
+	err.Wraps = nil
+	err.Stack = Stack{}
+	err.Details = append(err.Details,
+		Detail{
+			Message:  "loading user",
+			Location: Frame{Line: 12, File: "/src/user.go"},
+			Vars:     []Var{{Key: "id", Value: 42}, {Key: "name", Value: "bob"}},
+		},
+		Detail{
+			Location: Frame{Line: 30, File: "/src/handler.go"},
+			Vars:     []Var{{Key: "path", Value: "/users"}},
+		},
+	)
+	result := err.Error()
+	expect := `[oh no! [user.go:12] (id=42 name=bob) loading user [handler.go:30] (path=/users)]`
+	if result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+}
+
+func TestTextFormatterVarsNoStack(t *testing.T) {
+	err := getTestError()
+	err.Wraps = nil
+	err.Stack = Stack{}
+	err.Details[0].Location = Frame{Line: 12, File: "/src/user.go"}
+	err.Details[0].Vars = []Var{{Key: "id", Value: 42}}
+	result := err.Error()
+	expect := `[[user.go:12] (id=42) oh no!]`
+	if result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+}
+
+func TestDetailsFormatter(t *testing.T) {
+	err := getTestError()
+	err.Details = append(err.Details, Detail{}, Detail{Message: "try again"})
+	err.f = DetailsFormatter(": ")
+	result := err.Error()
+	expect := `oh no!: try again`
+	if result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+}
+
+func TestHostInfo(t *testing.T) {
+	host := HostInfo()
+	SetHostInfo("node-7")
+	defer SetHostInfo(host)
+	err := getTestError()
+	err.Stack = Stack{}
+	err.f = NewTextFormatter(ShowHostInfo(true))
+	result := err.Error()
+	expect := `bad error
+[oh no!]
+host=node-7`
+	if result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+	err.f = NewJSONFormatter(JSONIncludeHost(true))
+	if result := err.Error(); !strings.Contains(result, `"host":"node-7"`) {
+		t.Fatalf("expected host in output but got\n%v", result)
+	}
+	err.f = JSONFormatter()
+	if result := err.Error(); strings.Contains(result, `"host"`) {
+		t.Fatalf("expected no host in output but got\n%v", result)
+	}
+}
+
+func TestTextFormatterStackMode(t *testing.T) {
+	err := getTestError()
+	err.Stack.Frames = append(err.Stack.Frames, Frame{Line: 12, File: "other.go", Function: "Fn"})
+	err.f = NewTextFormatter(StackMode(StackTop))
+	result := err.Error()
+	expect := `bad error
+[oh no!]
+
+At [file.go:0]`
+	if result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+	err.f = NewTextFormatter(StackMode(StackNone))
+	result = err.Error()
+	expect = `bad error
+[oh no!]`
+	if result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+}
+
+func TestTextFormatterFullNames(t *testing.T) {
+	err := getTestError()
+	err.Wraps = nil
+	err.Stack.Frames[0] = Frame{Line: 7, File: "/src/acme/x/y/y.go", Function: "github.com/acme/x/y.Foo[...]"}
+	err.f = NewTextFormatter(FullFunctionNames(false), FullFilePaths(true))
+	result := err.Error()
+	expect := `[oh no!]
+
+With Stacktrace:
+y.Foo[...] [/src/acme/x/y/y.go:7]`
+	if result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+	err.f = NewTextFormatter()
+	result = err.Error()
+	expect = `[oh no!]
+
+With Stacktrace:
+github.com/acme/x/y.Foo[...] [y.go:7]`
+	if result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+}
+
+func TestTextFormatterStackMinLevel(t *testing.T) {
+	err := getTestError()
+	err.f = NewTextFormatter(StackMinLevel(LevelFatal))
+	err.Level = LevelWarn
+	expect := `bad error
+[oh no!]`
+	if result := err.Error(); result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+	err.Level = LevelFatal
+	expect = `bad error
+[oh no!]
+
+With Stacktrace:
+FunctionName [file.go:0]`
+	if result := err.Error(); result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+}
+
+func TestTextFormatterStackHeadTail(t *testing.T) {
+	err := getTestError()
+	err.Wraps = nil
+	for i := 1; i <= 5; i++ {
+		err.Stack.Frames = append(err.Stack.Frames, Frame{Line: i, File: "other.go", Function: "Fn"})
+	}
+	err.f = NewTextFormatter(StackHeadTail(2, 1))
+	expect := `[oh no!]
+
+With Stacktrace:
+FunctionName [file.go:0]
+Fn [other.go:1]
+... (3 frames omitted) ...
+Fn [other.go:5]`
+	if result := err.Error(); result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+	err.f = NewTextFormatter(StackHeadTail(4, 2))
+	if result := err.Error(); strings.Contains(result, "omitted") {
+		t.Fatalf("expected a short enough stack to be printed in full but got\n%v", result)
+	}
+}
+
+func TestTextFormatterDedupeStackFrames(t *testing.T) {
+	shared := []Frame{{Line: 7, File: "main.go", Function: "main.run"}, {Line: 3, File: "main.go", Function: "main.main"}}
+	inner := &Error{
+		Stack:   Stack{Frames: append([]Frame{{Line: 12, File: "db.go", Function: "db.Query"}}, shared...)},
+		Details: []Detail{{Message: "inner"}},
+	}
+	outer := Error{
+		Wraps:   inner,
+		Stack:   Stack{Frames: append([]Frame{{Line: 20, File: "user.go", Function: "user.Load"}}, shared...)},
+		Details: []Detail{{Message: "outer"}},
+		f:       NewTextFormatter(DedupeStackFrames(true)),
+	}
+	expect := `[inner]
+
+With Stacktrace:
+db.Query [db.go:12]
+main.run [main.go:7]
+main.main [main.go:3]
+[outer]
+
+With Stacktrace:
+user.Load [user.go:20]
+... (same as above)`
+	if result := outer.Error(); result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+	outer.f = textFormatter{}
+	if result := outer.Error(); strings.Contains(result, "same as above") {
+		t.Fatalf("expected the frames to be kept by default but got\n%v", result)
+	}
+}
+
+func TestTextFormatterStackFirst(t *testing.T) {
+	err := getTestError()
+	err.f = NewTextFormatter(StackFirst(true))
+	expect := `With Stacktrace:
+FunctionName [file.go:0]
+
+bad error
+[oh no!]`
+	if result := err.Error(); result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+}
+
+func TestSingleLine(t *testing.T) {
+	err := getTestError()
+	err.Stack.Frames = append(err.Stack.Frames, Frame{Line: 12, File: "other.go", Function: "Fn"})
+	expect := "bad error | [oh no!] | With Stacktrace: | FunctionName [file.go:0] | Fn [other.go:12]"
+	if result := SingleLine(&err); result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+	if SingleLine(nil) != "" {
+		t.Fatal("expected an empty string for a nil error")
+	}
+}
+
+func TestTextFormatterDedupeWrappedMessage(t *testing.T) {
+	err := getTestError()
+	err.Stack = Stack{}
+	err.Details = []Detail{{Message: "loading config: bad error"}}
+	err.f = NewTextFormatter(DedupeWrappedMessage(true))
+	if result := err.Error(); result != "[loading config: bad error]" {
+		t.Fatalf("expected the duplicate wrapped message to be left out but got %q", result)
+	}
+	err.Details = []Detail{{Message: "loading config"}}
+	if result := err.Error(); result != "bad error\n[loading config]" {
+		t.Fatalf("expected the wrapped message to be kept but got %q", result)
+	}
+}
+
+func TestTextFormatterNestedError(t *testing.T) {
+	inner := getTestError()
+	inner.Stack = Stack{}
+	inner.Details = []Detail{{Message: "first"}, {Message: "second"}}
+	inner.f = JSONFormatter()
+	outer := getTestError()
+	outer.Wraps = &inner
+	outer.Stack = Stack{}
+	outer.f = NewTextFormatter(ReverseDetails(true))
+	result := outer.Error()
+	expect := `bad error
+[second first]
+[oh no!]`
+	if result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+}
+
+func TestTextFormatterVerbs(t *testing.T) {
+	err := getTestError()
+	if result := fmt.Sprintf("%q", &err); result != `"bad error\n[oh no!]"` {
+		t.Fatalf("unexpected %%q output: %v", result)
+	}
+	if result := fmt.Sprintf("%j", &err); !strings.HasPrefix(result, `{"version":`) {
+		t.Fatalf("unexpected %%j output: %v", result)
+	}
+	if result := fmt.Sprintf("%d", &err); result != fmt.Sprintf("%v", &err) {
+		t.Fatalf("unexpected %%d output: %v", result)
+	}
+	if result := fmt.Sprintf("%s", &err); !strings.HasSuffix(result, "\n[file.go:0]") {
+		t.Fatalf("unexpected %%s output: %v", result)
+	}
+}
+
+func TestNDJSONFormatter(t *testing.T) {
+	inner := getTestError()
+	inner.Stack = Stack{}
+	outer := getTestError()
+	outer.Wraps = fmt.Errorf("context: %w", &inner)
+	outer.Details = []Detail{{Message: "outer"}}
+	outer.Stack = Stack{}
+	outer.f = NDJSONFormatter()
+	lines := strings.Split(outer.Error(), "\n")
+	expect := []string{
+		`{"depth":0,"version":5,"message":"outer","details":["outer"]}`,
+		`{"depth":1,"version":5,"message":"context"}`,
+		`{"depth":2,"version":5,"message":"oh no!","wraps":"bad error","details":["oh no!"]}`,
+	}
+	if strings.Join(lines, "\n") != strings.Join(expect, "\n") {
+		t.Fatalf("Expected\n%v\nbut got\n%v", strings.Join(expect, "\n"), strings.Join(lines, "\n"))
+	}
+}
+
+func TestNDJSONFormatterCycle(t *testing.T) {
+	err := getTestError()
+	err.Stack = Stack{}
+	err.Wraps = &err
+	err.f = NDJSONFormatter()
+	lines := strings.Split(err.Error(), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[1], `"wraps":"...[cycle detected]"`) {
+		t.Fatalf("unexpected output:\n%v", strings.Join(lines, "\n"))
+	}
+}
+
+func TestTextFormatterShowSummary(t *testing.T) {
+	inner := getTestError()
+	inner.Stack = Stack{}
+	inner.Wraps = errors.New("connection refused")
+	outer := getTestError()
+	outer.Wraps = fmt.Errorf("dial: %w", &inner)
+	outer.Details = []Detail{{Message: "startup failed"}}
+	outer.Stack = Stack{}
+	outer.f = NewTextFormatter(ShowSummary(true))
+	result := outer.Error()
+	expect := `summary: startup failed (caused by: connection refused)
+dial: connection refused
+[oh no!]
+[startup failed]`
+	if result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+}
+
+func TestTextFormatterChainHeadersOnce(t *testing.T) {
+	setTestVCSInfo(t, "", "", false)
+	version, commit := BuildInfo()
+	SetBuildInfo("v1.2.3", "abc123")
+	defer SetBuildInfo(version, commit)
+	inner := getTestError()
+	inner.Stack = Stack{}
+	inner.Wraps = nil
+	inner.Details = []Detail{{Message: "inner"}}
+	outer := getTestError()
+	outer.Wraps = &inner
+	outer.Details = []Detail{{Message: "outer"}}
+	outer.Stack = Stack{}
+	outer.f = NewTextFormatter(ShowSummary(true), ShowBuildInfo(true))
+	result := outer.Error()
+	expect := `summary: outer (caused by: inner)
+[inner]
+[outer]
+build=v1.2.3 (abc123)`
+	if result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+}
+
+func TestTextFormatterShowSummaryNoCause(t *testing.T) {
+	err := getTestError()
+	err.Wraps = nil
+	err.Stack = Stack{}
+	err.f = NewTextFormatter(ShowSummary(true))
+	result := err.Error()
+	expect := "summary: oh no!\n[oh no!]"
+	if result != expect {
+		t.Fatalf("Expected\n%v\nbut got\n%v", expect, result)
+	}
+}
+
+func TestShortFunctionName(t *testing.T) {
+	for function, expect := range map[string]string{
+		"github.com/a/b.(*T).Dial":            "(*T).Dial",
+		"github.com/a/b.Map[...].Get":         "Map[...].Get",
+		"github.com/a/b.Do[github.com/c/d.T]": "Do[github.com/c/d.T]",
+		"main.main":                           "main",
+	} {
+		if result := shortFunctionName(function); result != expect {
+			t.Fatalf("expected %q for %q but got %q", expect, function, result)
+		}
+	}
+}
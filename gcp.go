@@ -0,0 +1,76 @@
+package evs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// GCPFormatter returns a [Formatter] which writes the error as a single JSON object in the shape that Google
+// Cloud Logging understands:
+//
+//   - "severity": the level of the error (see [LevelOf]) as a Cloud Logging severity, e.g. "WARNING"
+//   - "message": the [Error.Summary]
+//   - "logging.googleapis.com/sourceLocation": the file, line, and function of the innermost frame
+//   - "stack_trace": the summary followed by the stack in the layout of a Go panic, which is what Cloud Error
+//     Reporting needs in order to group the errors
+//
+// The location and the stack trace are left out if the error has no stack.
+func GCPFormatter() Formatter {
+	return gcpFormatter{}
+}
+
+// gcpFormatter writes errors as Google Cloud Logging entries.
+type gcpFormatter struct{}
+
+type gcpEntry struct {
+	Severity       string             `json:"severity"`
+	Message        string             `json:"message"`
+	SourceLocation *gcpSourceLocation `json:"logging.googleapis.com/sourceLocation,omitempty"`
+	StackTrace     string             `json:"stack_trace,omitempty"`
+}
+
+type gcpSourceLocation struct {
+	File     string `json:"file"`
+	Line     string `json:"line"`
+	Function string `json:"function"`
+}
+
+// Format implements the [Formatter] interface.
+func (f gcpFormatter) Format(e *Error, s fmt.State, verb rune) {
+	entry := gcpEntry{
+		Severity: gcpSeverity(LevelOf(e)),
+		Message:  e.Summary(),
+	}
+	if top, ok := e.Stack.Top(); ok {
+		entry.SourceLocation = &gcpSourceLocation{
+			File:     top.File,
+			Line:     strconv.Itoa(top.Line),
+			Function: top.Function,
+		}
+		entry.StackTrace = gcpStackTrace(entry.Message, e.Stack)
+	}
+	data, _ := json.Marshal(entry)
+	_, _ = s.Write(data)
+}
+
+// gcpSeverity maps a level onto the matching Cloud Logging severity.
+func gcpSeverity(level Level) string {
+	switch level {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARNING"
+	case LevelFatal:
+		return "CRITICAL"
+	default:
+		return "ERROR"
+	}
+}
+
+// gcpStackTrace renders the stack the way the Go runtime prints it when panicking.
+func gcpStackTrace(msg string, stack Stack) string {
+	return msg + "\n\ngoroutine 1 [running]:\n" + stack.Pprof()
+}
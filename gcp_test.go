@@ -0,0 +1,37 @@
+package evs
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestGCPFormatter(t *testing.T) {
+	err := getTestError()
+	err.Level = LevelWarn
+	err.f = GCPFormatter()
+	var entry map[string]any
+	if jsonErr := json.Unmarshal([]byte(err.Error()), &entry); jsonErr != nil {
+		t.Fatalf("invalid JSON %v: %v", err.Error(), jsonErr)
+	}
+	expect := map[string]any{
+		"severity": "WARNING",
+		"message":  "oh no!",
+		"logging.googleapis.com/sourceLocation": map[string]any{
+			"file": "file.go", "line": "0", "function": "FunctionName",
+		},
+		"stack_trace": "oh no!\n\ngoroutine 1 [running]:\nFunctionName(...)\n\tfile.go:0",
+	}
+	if fmt.Sprint(entry) != fmt.Sprint(expect) {
+		t.Fatalf("expected\n%v\nbut got\n%v", expect, entry)
+	}
+}
+
+func TestGCPFormatterNoStack(t *testing.T) {
+	err := &Error{Wraps: errors.New("bad error"), f: GCPFormatter()}
+	expect := `{"severity":"ERROR","message":"bad error"}`
+	if result := err.Error(); result != expect {
+		t.Fatalf("expected %v but got %v", expect, result)
+	}
+}
@@ -0,0 +1,29 @@
+package evs
+
+import (
+	"sync"
+)
+
+var (
+	helpURLsMu sync.RWMutex
+	helpURLs   = map[Kind]string{}
+)
+
+// RegisterHelpURL sets the documentation URL for errors of the given [Kind]. See [HelpURL].
+func RegisterHelpURL(k Kind, url string) {
+	helpURLsMu.Lock()
+	defer helpURLsMu.Unlock()
+	helpURLs[k] = url
+}
+
+// HelpURL returns the documentation URL registered via [RegisterHelpURL] for the [Kind] of err (see [KindOf]).
+// The text and JSON formatters can include it with [ShowHelpURL] and [JSONIncludeHelpURL].
+func HelpURL(err error) (string, bool) {
+	if err == nil {
+		return "", false
+	}
+	helpURLsMu.RLock()
+	defer helpURLsMu.RUnlock()
+	url, ok := helpURLs[KindOf(err)]
+	return url, ok
+}
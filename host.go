@@ -0,0 +1,30 @@
+package evs
+
+import (
+	"os"
+	"sync"
+)
+
+var (
+	hostInfoMu sync.RWMutex
+	hostname   string
+)
+
+func init() {
+	hostname, _ = os.Hostname()
+}
+
+// SetHostInfo sets the hostname that formatters include when they are configured to do so (see
+// [ShowHostInfo] and [JSONIncludeHost]). By default it is the value reported by [os.Hostname].
+func SetHostInfo(host string) {
+	hostInfoMu.Lock()
+	defer hostInfoMu.Unlock()
+	hostname = host
+}
+
+// HostInfo returns the hostname set via [SetHostInfo].
+func HostInfo() string {
+	hostInfoMu.RLock()
+	defer hostInfoMu.RUnlock()
+	return hostname
+}
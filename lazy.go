@@ -0,0 +1,47 @@
+package evs
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// LazyValue is a structured field value that is only computed when it is first needed, which is typically when
+// the error gets formatted. The result is memoized, so the function runs at most once. See [Record.WithLazy].
+type LazyValue struct {
+	once  sync.Once
+	fn    func() any
+	value any
+}
+
+// Value computes the value (if it hasn't been computed yet) and returns it. If the function panics, the panic
+// is recovered and the value becomes a "<lazy value panicked: ...>" string.
+func (v *LazyValue) Value() any {
+	v.once.Do(func() {
+		defer func() {
+			if r := recover(); r != nil {
+				v.value = fmt.Sprintf("<lazy value panicked: %v>", r)
+			}
+		}()
+		v.value = v.fn()
+	})
+	return v.value
+}
+
+// String implements the [fmt.Stringer] interface.
+func (v *LazyValue) String() string {
+	return fmt.Sprintf("%v", v.Value())
+}
+
+// MarshalJSON implements the [json.Marshaler] interface.
+func (v *LazyValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.Value())
+}
+
+// resolveField returns the computed value if value is a [LazyValue] and value itself otherwise.
+func resolveField(value any) any {
+	if lazy, ok := value.(*LazyValue); ok {
+		return lazy.Value()
+	}
+	return value
+}
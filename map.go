@@ -0,0 +1,65 @@
+package evs
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ToMap converts err into a generic structured representation that any logging backend which accepts a map (or
+// key/value pairs) can consume. The nearest [Error] in the chain provides the content:
+//
+//   - "message": the [Error.Summary]
+//   - "wraps": the message of the wrapped error, if there is one
+//   - "kind": the [Kind], if it is known
+//   - "level": the name of the level of the chain (see [LevelOf]), e.g. "ERROR"
+//   - "op": the operation of the chain (see [OpOf]), if there is one
+//   - "tags": the tags of the whole chain (see [Tags]), if there are any
+//   - "details": a slice of maps with a "message" key and, if set, a "level" key
+//   - "stack": a slice of "function file:line" strings, if a stack was captured
+//
+// The structured fields are merged into the top level of the map, but they never override the keys above.
+// If the chain contains no [Error], the map only holds the "message". It returns nil if err is nil.
+func ToMap(err error) map[string]any {
+	if err == nil {
+		return nil
+	}
+	e := &Error{}
+	if !errors.As(err, &e) {
+		return map[string]any{"message": err.Error()}
+	}
+	m := map[string]any{}
+	for key, value := range e.Fields {
+		m[key] = resolveField(value)
+	}
+	m["message"] = e.Summary()
+	if e.Wraps != nil && !chainContains(e.Wraps, e) {
+		m["wraps"] = e.Wraps.Error()
+	}
+	if e.Kind != KindUnknown {
+		m["kind"] = string(e.Kind)
+	}
+	m["level"] = LevelOf(err).String()
+	if op, ok := OpOf(err); ok {
+		m["op"] = op
+	}
+	if tags := Tags(err); len(tags) > 0 {
+		m["tags"] = tags
+	}
+	details := make([]map[string]any, 0, len(e.Details))
+	for _, detail := range e.Details {
+		d := map[string]any{"message": detail.Message}
+		if detail.Level != LevelUnset {
+			d["level"] = detail.Level.String()
+		}
+		details = append(details, d)
+	}
+	m["details"] = details
+	if len(e.Stack.Frames) > 0 {
+		stack := make([]string, 0, len(e.Stack.Frames))
+		for _, frame := range e.Stack.Frames {
+			stack = append(stack, fmt.Sprintf("%v %v:%v", frame.Function, frame.File, frame.Line))
+		}
+		m["stack"] = stack
+	}
+	return m
+}
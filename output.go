@@ -0,0 +1,138 @@
+package evs
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"sync"
+)
+
+// SingleLineSeparator is what [SingleLine] puts in place of line breaks.
+var SingleLineSeparator = " | "
+
+// bufferPool holds the buffers used by [String] and [VerboseString] so that repeated calls don't need to
+// allocate and grow a new buffer every time.
+var bufferPool = sync.Pool{
+	New: func() any {
+		return &bufferState{}
+	},
+}
+
+// bufferState is a [writerState] that writes into its own buffer.
+type bufferState struct {
+	buf   bytes.Buffer
+	state writerState
+}
+
+// Sink binds a [Formatter] to the [io.Writer] that its output should be written to.
+type Sink struct {
+	Writer    io.Writer
+	Formatter Formatter
+}
+
+// FormatTo writes err to w using the given [Formatter], without going through the fmt package. If f is nil,
+// the formatter of the error itself is used. The formatter sees a [fmt.State] with no flags set and with
+// neither a width nor a precision, so output matches formatting with the plain verb (e.g. %v rather than %+v).
+// A panic in the formatter is recovered in the same way as in [Error.Format]. It returns the first error returned
+// by w.
+func FormatTo(w io.Writer, err *Error, f Formatter, verb rune) error {
+	if f == nil {
+		f = err.f
+	}
+	state := &writerState{w: w}
+	safeFormat(f, err, state, verb)
+	return state.err
+}
+
+// String formats err with the default text formatter using the short form of the stack frames (as with %s)
+// without going through the fmt package.
+func String(err *Error) string {
+	return formatString(err, 's', false)
+}
+
+// VerboseString is the same as [String] but uses the long form of the stack frames, producing the same text
+// as fmt.Sprintf("%+v", err) with the default text formatter.
+func VerboseString(err *Error) string {
+	return formatString(err, 'v', true)
+}
+
+func formatString(err *Error, verb rune, plus bool) string {
+	b := bufferPool.Get().(*bufferState)
+	defer bufferPool.Put(b)
+	b.buf.Reset()
+	b.state = writerState{w: &b.buf, plus: plus}
+	safeFormat(TextFormatter(), err, &b.state, verb)
+	return b.buf.String()
+}
+
+// FormatBytes formats err with its own [Formatter] (in the same way as [FormatTo]) and returns the output. The
+// formatting happens in a pooled buffer, so the only allocation left is the returned slice, which is a copy that
+// belongs to the caller and stays valid however long it is kept.
+func FormatBytes(err *Error, verb rune) []byte {
+	b := bufferPool.Get().(*bufferState)
+	defer bufferPool.Put(b)
+	b.buf.Reset()
+	b.state = writerState{w: &b.buf}
+	safeFormat(err.f, err, &b.state, verb)
+	return bytes.Clone(b.buf.Bytes())
+}
+
+// SingleLine formats err (using its own [Formatter] if it is an [Error]) and replaces every run of line breaks
+// with [SingleLineSeparator], so the result is guaranteed to fit on a single line. This is meant for log sinks
+// that can't handle multi-line values. It returns an empty string if err is nil.
+func SingleLine(err error) string {
+	if err == nil {
+		return ""
+	}
+	lines := strings.FieldsFunc(formattedString(err), func(r rune) bool { return r == '\n' || r == '\r' })
+	return strings.Join(lines, SingleLineSeparator)
+}
+
+// Tee formats err once for each of the given sinks. This lets a single call produce several representations
+// of the same error, e.g. human readable text to stderr and JSON to a log file. Every sink is attempted, and
+// the first write error encountered (if any) is returned.
+func Tee(err *Error, verb rune, sinks ...Sink) error {
+	var firstErr error
+	for _, sink := range sinks {
+		if writeErr := FormatTo(sink.Writer, err, sink.Formatter, verb); writeErr != nil && firstErr == nil {
+			firstErr = writeErr
+		}
+	}
+	return firstErr
+}
+
+// writerState adapts an [io.Writer] into a [fmt.State] with no width or precision set. The only flag it can
+// report is '+'.
+type writerState struct {
+	w    io.Writer
+	err  error
+	plus bool
+}
+
+// Write implements the [io.Writer] interface and records the first error returned by the underlying writer.
+func (s *writerState) Write(b []byte) (int, error) {
+	n, err := s.w.Write(b)
+	if err != nil && s.err == nil {
+		s.err = err
+	}
+	return n, err
+}
+
+// WriteString implements the [io.StringWriter] interface so that io.WriteString doesn't need to copy the
+// string into a new byte slice when the underlying writer can take strings directly.
+func (s *writerState) WriteString(str string) (int, error) {
+	n, err := io.WriteString(s.w, str)
+	if err != nil && s.err == nil {
+		s.err = err
+	}
+	return n, err
+}
+
+// Width implements the [fmt.State] interface.
+func (s *writerState) Width() (int, bool) { return 0, false }
+
+// Precision implements the [fmt.State] interface.
+func (s *writerState) Precision() (int, bool) { return 0, false }
+
+// Flag implements the [fmt.State] interface.
+func (s *writerState) Flag(c int) bool { return c == '+' && s.plus }
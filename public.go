@@ -0,0 +1,41 @@
+package evs
+
+import (
+	"sync"
+)
+
+const defaultPublicMessage = "internal error"
+
+var (
+	publicMessagesMu sync.RWMutex
+	publicMessages   = map[Kind]string{}
+)
+
+// RegisterPublicMessage sets the message that [Public] uses for errors of the given [Kind].
+func RegisterPublicMessage(k Kind, msg string) {
+	publicMessagesMu.Lock()
+	defer publicMessagesMu.Unlock()
+	publicMessages[k] = msg
+}
+
+// Public returns a new [Error] that is safe to hand to external clients. It only keeps the [Kind] of the given
+// error along with the message registered for that Kind via [RegisterPublicMessage]. The stack, details,
+// fields, and wrapped error are all dropped. If no message has been registered for the Kind, the message
+// is a generic "internal error". It returns nil if err is nil.
+func Public(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	k := KindOf(err)
+	publicMessagesMu.RLock()
+	msg, ok := publicMessages[k]
+	publicMessagesMu.RUnlock()
+	if !ok {
+		msg = defaultPublicMessage
+	}
+	return &Error{
+		Details: []Detail{{Message: msg}},
+		Kind:    k,
+		f:       GetFormatterFunc(),
+	}
+}
@@ -1,7 +1,11 @@
 package evs
 
 import (
+	"errors"
 	"fmt"
+	"io"
+	"sync"
+	"time"
 )
 
 // Record is a builder type that is used to build up an [Error]. Once you have created the error
@@ -19,14 +23,14 @@ func newRecord(err *Error) *Record {
 // New creates a new [Record] with the given message and the Std error type.
 func New(msg string) *Record {
 	err := newError(initialSkip)
-	err.Details = append(err.Details, msg)
+	err.Details = append(err.Details, callerDetail(initialSkip, msg, LevelUnset))
 	return newRecord(err)
 }
 
 // Newf creates a new [Record] with the given formatted message and the Std error type.
 func Newf(msg string, args ...any) *Record {
 	err := newError(initialSkip)
-	err.Details = append(err.Details, fmt.Sprintf(msg, args...))
+	err.Details = append(err.Details, callerDetail(initialSkip, fmt.Sprintf(msg, args...), LevelUnset))
 	return newRecord(err)
 }
 
@@ -34,7 +38,10 @@ func Newf(msg string, args ...any) *Record {
 // [Error]. If the given error is not nil, it first checks to see if it already contains a [Error]. If it does, it
 // directly sets the underlying [Error] to that error. Otherwise, it creates a new [Error] that wraps the given error.
 // Use this method if you don't intend to "wrap" [Error]s but rather just have one single error that you
-// can pass around.
+// can pass around. Either way, [errors.Is] and [errors.As] keep matching everything they matched on the
+// given error, with one limitation when an existing [Error] is reused: any plain wrappers around it (e.g.
+// from fmt.Errorf) are dropped, so sentinels that only those wrappers matched are lost. Joined errors are
+// always wrapped whole rather than searched, so all of their branches are kept.
 func From(err error) *Record {
 	if err == nil {
 		return newRecord(nil)
@@ -43,12 +50,196 @@ func From(err error) *Record {
 	return newRecord(newErr)
 }
 
+// Coerce returns err itself if it is an [Error], and otherwise wraps it in a new [Error] (with a stacktrace but
+// without a message). Unlike [From], it never extracts an [Error] from deeper in the chain, so the message of
+// err is always kept intact. It returns nil if err is nil.
+func Coerce(err error) *Error {
+	return coerce(initialSkip, err)
+}
+
+func coerce(skip int, err error) *Error {
+	skip++
+	if err == nil {
+		return nil
+	}
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	newErr := newError(skip)
+	newErr.Wraps = err
+	inheritFields(newErr)
+	enrich(newErr)
+	return newErr
+}
+
+// WithCapturedAt is meant to be called where an error is handed off from one goroutine to another (e.g. after
+// receiving it from a channel). It records the current stack as [Error.CapturedAt] on the [Error] in err, while
+// keeping the stack of where the error was originally created, so that the output shows both halves of the
+// async call path (the handler's under a "Continued from:" header). If err doesn't contain an [Error] yet, a new
+// one is created, whose stack already is the current one. An [Error] that already has a CapturedAt keeps it,
+// so handing a shared error on again doesn't overwrite where it was first picked up. It returns nil if err is
+// nil.
+func WithCapturedAt(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	newErr := from(initialSkip, err)
+	if IncludeStack && chainContains(err, newErr) && len(newErr.CapturedAt.Frames) == 0 {
+		newErr.CapturedAt = GetStack(initialSkip)
+	}
+	return newErr
+}
+
+// CloseWrap closes c and records any error from doing so in *errp. It is meant to be deferred, typically with a
+// named error result, so that errors from Close aren't silently lost:
+//
+//	defer evs.CloseWrap(&err, f, "closing config file")
+//
+// The close error is wrapped in a new [Error] with the given message and a stack pointing at the deferred call.
+// If *errp is nil it becomes that error, and otherwise both are joined (see [errors.Join]) so neither is lost.
+func CloseWrap(errp *error, c io.Closer, msg string) {
+	closeErr := c.Close()
+	if closeErr == nil {
+		return
+	}
+	newErr := newError(initialSkip)
+	newErr.Wraps = closeErr
+	newErr.Details = append(newErr.Details, callerDetail(initialSkip, msg, LevelUnset))
+	inheritFields(newErr)
+	enrich(newErr)
+	if *errp == nil {
+		*errp = newErr
+		return
+	}
+	*errp = errors.Join(*errp, newErr)
+}
+
+// WrapSentinel wraps err with the given message and tags it with sentinel, so that both errors.Is(result, sentinel)
+// and errors.Is(result, err) are true while err remains the wrapped cause. It returns nil if err is nil.
+func WrapSentinel(err error, sentinel error, msg string) error {
+	if err == nil {
+		return nil
+	}
+	newErr := from(initialSkip, err)
+	newErr.Details = append(newErr.Details, callerDetail(initialSkip, msg, LevelUnset))
+	return newRecord(newErr).Sentinel(sentinel).Err()
+}
+
+// WrapMessage wraps the message of err with msg, without keeping a reference to err itself. This is useful when an
+// error crosses a trust or serialization boundary, or holds onto large objects (e.g. a response body) that
+// shouldn't be retained. The output looks the same as a normal wrap, but since only the text of err is kept,
+// [errors.Is] and [errors.As] no longer match err or anything in its chain. It returns nil if err is nil.
+func WrapMessage(err error, msg string) *Error {
+	if err == nil {
+		return nil
+	}
+	newErr := newError(initialSkip)
+	newErr.Wraps = detachedError(err.Error())
+	newErr.Details = append(newErr.Details, callerDetail(initialSkip, msg, LevelUnset))
+	return newErr
+}
+
+// Promote flattens err into a new [Error] whose only detail is the Error() string of err, with a fresh stack
+// and nothing wrapped. Unlike [WrapMessage], the text becomes the message of the new error rather than a wrapped
+// line. Nothing else about err is kept, so [errors.Is] and [errors.As] no longer match it. It returns nil if err
+// is nil.
+func Promote(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	newErr := newError(initialSkip)
+	newErr.Details = append(newErr.Details, newDetail(err.Error(), LevelUnset))
+	return newErr
+}
+
+// detachedError holds the message of an error that was wrapped via [WrapMessage].
+type detachedError string
+
+// Error implements the error interface.
+func (err detachedError) Error() string { return string(err) }
+
+// WrapEach wraps every non-nil error in errs with the message returned by msgFn for its index, and joins the
+// results (see [errors.Join]) into a single error whose stacktrace points at the call to WrapEach. The
+// individually wrapped errors don't capture their own (identical) stacks. Every error is wrapped in a new
+// [Error], even if it already is one, so the errors in errs are never changed. It returns nil if every error in
+// errs is nil.
+func WrapEach(errs []error, msgFn func(i int) string) error {
+	wrapped := []error{}
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		newErr := newError(initialSkip)
+		newErr.Stack = Stack{}
+		newErr.Wraps = err
+		inheritFields(newErr)
+		enrich(newErr)
+		newErr.Details = append(newErr.Details, callerDetail(initialSkip, msgFn(i), LevelUnset))
+		wrapped = append(wrapped, newErr)
+	}
+	if len(wrapped) == 0 {
+		return nil
+	}
+	return from(initialSkip, errors.Join(wrapped...))
+}
+
 // Msg provides a mechanism to set the error message directly.
 func (rec *Record) Msg(msg string) *Record {
 	if rec.err == nil {
 		return rec
 	}
-	rec.err.Details = append(rec.err.Details, msg)
+	rec.err.Details = append(rec.err.Details, callerDetail(initialSkip, msg, LevelUnset))
+	return rec
+}
+
+// Prefix prepends prefix (followed by ": ") to the message of the most recently added detail, e.g. turning
+// "connection refused" into "startup failed: connection refused". Use it to add high-level context to an
+// existing message; use [Record.Msg] to add a separate piece of context, or [From] and [Record.Set] to add a new
+// layer (with its own stack) on top of an error. If the error has no details yet, a detail holding just the
+// prefix is added.
+func (rec *Record) Prefix(prefix string) *Record {
+	if rec.err == nil {
+		return rec
+	}
+	if len(rec.err.Details) == 0 {
+		rec.err.Details = append(rec.err.Details, newDetail(prefix, LevelUnset))
+		return rec
+	}
+	detail := &rec.err.Details[len(rec.err.Details)-1]
+	if detail.Message == "" {
+		detail.Message = prefix
+	} else {
+		detail.Message = prefix + ": " + detail.Message
+	}
+	return rec
+}
+
+// Var records a caller-provided value on the most recently added detail, along with the location of the call
+// to Var, so that dynamic values are tied to a specific propagation point. The text formatter renders such a
+// detail as "[file:line] (key=value) message". If the error has no details yet, a detail without a message is
+// added. Nothing extra is captured for errors that never use Var.
+func (rec *Record) Var(key string, value any) *Record {
+	if rec.err == nil {
+		return rec
+	}
+	if len(rec.err.Details) == 0 {
+		rec.err.Details = append(rec.err.Details, newDetail("", LevelUnset))
+	}
+	detail := &rec.err.Details[len(rec.err.Details)-1]
+	if detail.Location == (Frame{}) {
+		detail.Location = CurrentFrame(1)
+	}
+	detail.Vars = append(detail.Vars, Var{Key: key, Value: value})
+	return rec
+}
+
+// MsgAt is the same as [Record.Msg] except that the detail is given a [Level]. Formatters can be configured
+// to hide details below some level (see [MinDetailLevel]).
+func (rec *Record) MsgAt(level Level, msg string) *Record {
+	if rec.err == nil {
+		return rec
+	}
+	rec.err.Details = append(rec.err.Details, callerDetail(initialSkip, msg, level))
 	return rec
 }
 
@@ -57,7 +248,7 @@ func (rec *Record) Msgf(msg string, args ...any) *Record {
 	if rec.err == nil {
 		return rec
 	}
-	rec.err.Details = append(rec.err.Details, fmt.Sprintf(msg, args...))
+	rec.err.Details = append(rec.err.Details, callerDetail(initialSkip, fmt.Sprintf(msg, args...), LevelUnset))
 	return rec
 }
 
@@ -73,15 +264,57 @@ func (rec *Record) DropStack() *Record {
 }
 
 // Set directly assigns the given error to the internal wrapped error. It overrides any previously wrapped
-// error that may have already been in place.
+// error that may have already been in place. If wraps already is as deep as [SetMaxWrapDepth] allows, a copy
+// of it trimmed by [TrimChain] is wrapped instead, so the chain doesn't grow any further. The structured fields
+// of the wrapped chain are inherited the same way [From] inherits them, without overriding fields that the
+// error already has, and then the registered enrichers (see [RegisterEnricher]) run on wraps.
 func (rec *Record) Set(wraps error) *Record {
 	if rec.err == nil {
 		return rec
 	}
+	if limit := wrapDepthLimit(); wrapDepth(wraps) >= limit {
+		// The trimmed chain has at most limit-2 kept links plus the root, and this error adds one more.
+		wraps = TrimChain(wraps, limit-2)
+	}
 	rec.err.Wraps = wraps
+	inheritFields(rec.err)
+	enrich(rec.err)
+	rec.resetIsCache()
+	return rec
+}
+
+// Sentinel tags the error with the given sentinel so that [errors.Is] reports a match for it, without
+// changing the wrapped error. This is useful to categorize an error while keeping the original cause.
+func (rec *Record) Sentinel(sentinel error) *Record {
+	if rec.err == nil {
+		return rec
+	}
+	rec.err.sentinels = append(rec.err.sentinels, sentinel)
+	rec.resetIsCache()
+	return rec
+}
+
+// CacheIs makes the error remember the result of every [errors.Is] check against a comparable target (such as
+// io.EOF), which speeds up hot loops that test the same error over and over. Only matches get faster: when
+// the cached answer is false, [errors.Is] still goes on to unwrap and walk the rest of the chain, since it
+// can't be told to stop. The results are identical to walking the chain, as long as the chain isn't modified
+// other than through this [Record] after the first check. Modifying [Error.Wraps] directly afterwards can
+// leave stale results behind.
+func (rec *Record) CacheIs() *Record {
+	if rec.err == nil {
+		return rec
+	}
+	rec.err.isCache = &sync.Map{}
 	return rec
 }
 
+// resetIsCache drops any cached [Error.Is] results after the chain has changed.
+func (rec *Record) resetIsCache() {
+	if rec.err.isCache != nil {
+		rec.err.isCache = &sync.Map{}
+	}
+}
+
 // Fmt allows you to set the Formatter you'd like to use which dictates how the messages are
 // printed out.
 func (rec *Record) Fmt(f Formatter) *Record {
@@ -101,6 +334,82 @@ func (rec *Record) Kind(k Kind) *Record {
 	return rec
 }
 
+// WithLevel sets how severe the error is. See [LevelOf].
+func (rec *Record) WithLevel(level Level) *Record {
+	if rec.err == nil {
+		return rec
+	}
+	rec.err.Level = level
+	return rec
+}
+
+// WithHint attaches a short remediation hint to the error, which is guidance for operators on what to do rather
+// than a description of what went wrong (e.g. "check the DATABASE_URL env var"). See [Hint].
+func (rec *Record) WithHint(hint string) *Record {
+	if rec.err == nil {
+		return rec
+	}
+	rec.err.Hint = hint
+	return rec
+}
+
+// WithOp attaches the name of the operation that failed (e.g. "db.Query" or "http.Get"), which is kept apart
+// from the message so that errors can be grouped by operation. See [OpOf].
+func (rec *Record) WithOp(op string) *Record {
+	if rec.err == nil {
+		return rec
+	}
+	rec.err.Op = op
+	return rec
+}
+
+// WithStatus attaches the HTTP status code that the error should be reported with. See [StatusCode] and [HasStatus].
+func (rec *Record) WithStatus(code int) *Record {
+	if rec.err == nil {
+		return rec
+	}
+	rec.err.Status = code
+	return rec
+}
+
+// WithRetryAfter tells the caller how long they should wait before retrying the operation that failed.
+func (rec *Record) WithRetryAfter(d time.Duration) *Record {
+	if rec.err == nil {
+		return rec
+	}
+	rec.err.RetryAfter = d
+	return rec
+}
+
+// WithTags adds the given tags to the error. Tags are free-form labels (e.g. "transient" or "user-facing") that can
+// be used to classify errors along several independent axes. See [Tags] and [HasTag].
+func (rec *Record) WithTags(tags ...string) *Record {
+	if rec.err == nil {
+		return rec
+	}
+	rec.err.Tags = append(rec.err.Tags, tags...)
+	return rec
+}
+
+// With attaches a structured field to the error. Setting the same key twice overrides the previous value.
+func (rec *Record) With(key string, value any) *Record {
+	if rec.err == nil {
+		return rec
+	}
+	if rec.err.Fields == nil {
+		rec.err.Fields = map[string]any{}
+	}
+	rec.err.Fields[key] = value
+	return rec
+}
+
+// WithLazy attaches a structured field whose value is computed by fn only when it is needed (e.g. when the error
+// is formatted). This avoids paying for expensive debug context on errors that are discarded. The field is
+// stored as a [LazyValue].
+func (rec *Record) WithLazy(key string, fn func() any) *Record {
+	return rec.With(key, &LazyValue{fn: fn})
+}
+
 // Err returns the error that you've built up via the other methods.
 func (rec *Record) Err() error {
 	if rec.err == nil {
@@ -0,0 +1,38 @@
+package evs
+
+import (
+	"reflect"
+	"sync"
+)
+
+var (
+	wrapRenderersMu sync.RWMutex
+	wrapRenderers   = map[reflect.Type]func(error) string{}
+)
+
+// RegisterWrapRenderer sets the function the text formatter uses to render wrapped errors that have the same
+// dynamic type as matchType, e.g. (*os.PathError)(nil). This lets you pull the structured fields of foreign
+// errors into a cleaner message than their Error() string. The type has to match exactly; errors that merely
+// wrap an error of that type aren't affected. Errors of unregistered types are rendered as before. Passing a
+// nil fn removes the renderer for the type.
+func RegisterWrapRenderer(matchType any, fn func(error) string) {
+	wrapRenderersMu.Lock()
+	defer wrapRenderersMu.Unlock()
+	t := reflect.TypeOf(matchType)
+	if fn == nil {
+		delete(wrapRenderers, t)
+		return
+	}
+	wrapRenderers[t] = fn
+}
+
+// wrapRenderer returns the renderer registered for the type of err.
+func wrapRenderer(err error) (func(error) string, bool) {
+	wrapRenderersMu.RLock()
+	defer wrapRenderersMu.RUnlock()
+	if len(wrapRenderers) == 0 {
+		return nil, false
+	}
+	fn, ok := wrapRenderers[reflect.TypeOf(err)]
+	return fn, ok
+}
@@ -0,0 +1,68 @@
+package evs
+
+import (
+	"math"
+	"math/rand"
+	"sync/atomic"
+)
+
+// randSource holds the generator built from the source set via [SetRandSource]. It is nil while the default is
+// used.
+var randSource atomic.Pointer[rand.Rand]
+
+// SetRandSource sets the source of the pseudo-random numbers that sampling (e.g. [SetStackSampleRate]) is based
+// on, so that tests can make it deterministic by using a seeded source. The source is used by every goroutine
+// that creates an error, and neither it nor the [rand.Rand] built from it are locked by this package, so it
+// must be safe for concurrent use (the sources returned by [rand.NewSource] are not, so wrap them with a mutex
+// if needed). Passing nil restores the default, which is the top-level source of math/rand; unless it was
+// seeded via rand.Seed, it is kept per P by the runtime and doesn't take a lock.
+func SetRandSource(src rand.Source) {
+	if src == nil {
+		randSource.Store(nil)
+		return
+	}
+	randSource.Store(rand.New(src))
+}
+
+// randFloat64 returns a pseudo-random number in [0.0,1.0) from the source set via [SetRandSource].
+func randFloat64() float64 {
+	if r := randSource.Load(); r != nil {
+		return r.Float64()
+	}
+	return rand.Float64()
+}
+
+// stackSampleRate holds the bits of the float64 set via [SetStackSampleRate].
+var stackSampleRate atomic.Uint64
+
+func init() {
+	stackSampleRate.Store(math.Float64bits(1))
+}
+
+// SetStackSampleRate sets the fraction of new errors that capture a stacktrace, from 1.0 (every error, which is
+// the default) to 0.0 (none). Each error rolls the dice on its own using a cheap pseudo-random number, so the
+// actual fraction is only approximately the rate. Values outside of the range are clamped. Errors that don't
+// get a stack format the same way as errors created while [IncludeStack] is false. The rate only matters while
+// [IncludeStack] is true.
+func SetStackSampleRate(rate float64) {
+	switch {
+	case !(rate > 0):
+		rate = 0
+	case rate > 1:
+		rate = 1
+	}
+	stackSampleRate.Store(math.Float64bits(rate))
+}
+
+// sampleStack reports whether a new error should capture a stacktrace.
+func sampleStack() bool {
+	rate := math.Float64frombits(stackSampleRate.Load())
+	switch {
+	case rate >= 1:
+		return true
+	case rate <= 0:
+		return false
+	default:
+		return randFloat64() < rate
+	}
+}
@@ -0,0 +1,65 @@
+package evs
+
+import (
+	"math/rand"
+	"slices"
+	"sync"
+	"testing"
+)
+
+func TestSetStackSampleRate(t *testing.T) {
+	defer SetStackSampleRate(1)
+	SetStackSampleRate(0)
+	if err := New("bad day").Err(); HasStack(err) {
+		t.Fatal("expected no stack with a sample rate of 0")
+	}
+	SetStackSampleRate(0.5)
+	sampled := 0
+	for i := 0; i < 1000; i++ {
+		if HasStack(New("bad day").Err()) {
+			sampled++
+		}
+	}
+	if sampled < 350 || sampled > 650 {
+		t.Fatalf("expected roughly half of the errors to have a stack but got %v of 1000", sampled)
+	}
+	SetStackSampleRate(2)
+	if err := New("bad day").Err(); !HasStack(err) {
+		t.Fatal("expected a stack with a sample rate above 1")
+	}
+}
+
+// lockedSource makes a source from rand.NewSource safe for concurrent use.
+type lockedSource struct {
+	mu  sync.Mutex
+	src rand.Source
+}
+
+func (s *lockedSource) Int63() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.src.Int63()
+}
+
+func (s *lockedSource) Seed(seed int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.src.Seed(seed)
+}
+
+func TestSetRandSource(t *testing.T) {
+	defer SetStackSampleRate(1)
+	defer SetRandSource(nil)
+	SetStackSampleRate(0.5)
+	sample := func() []bool {
+		SetRandSource(&lockedSource{src: rand.NewSource(42)})
+		out := make([]bool, 0, 100)
+		for i := 0; i < 100; i++ {
+			out = append(out, HasStack(New("bad day").Err()))
+		}
+		return out
+	}
+	if first, second := sample(), sample(); !slices.Equal(first, second) {
+		t.Fatal("expected the same seed to sample the same errors")
+	}
+}
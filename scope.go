@@ -0,0 +1,59 @@
+package evs
+
+import (
+	"errors"
+	"sync"
+)
+
+// Scope collects errors that should only be reported if an operation ultimately fails, e.g. errors from
+// attempts inside a transaction that may still be rolled back and retried successfully. The zero value is ready
+// to use, and a Scope is safe for concurrent use. It is typically resolved in a deferred function:
+//
+//	var scope evs.Scope
+//	defer func() {
+//		if err != nil {
+//			err = scope.Flush()
+//			return
+//		}
+//		scope.Discard()
+//	}()
+//
+// Make sure the final error is also added to the scope (or joined with the result of [Scope.Flush]) if it should
+// be part of the aggregate.
+type Scope struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+// Add records err in the scope. Nil errors are ignored.
+func (scope *Scope) Add(err error) {
+	if err == nil {
+		return
+	}
+	scope.mu.Lock()
+	defer scope.mu.Unlock()
+	scope.errs = append(scope.errs, err)
+}
+
+// Discard drops every error recorded so far without reporting any of them. Call it once the operation has
+// succeeded.
+func (scope *Scope) Discard() {
+	scope.mu.Lock()
+	defer scope.mu.Unlock()
+	scope.errs = nil
+}
+
+// Flush returns an [Error] that joins (see [errors.Join]) every error recorded so far, with a stack pointing at
+// the call to Flush, and empties the scope. It returns nil if no errors were recorded.
+func (scope *Scope) Flush() error {
+	scope.mu.Lock()
+	errs := scope.errs
+	scope.errs = nil
+	scope.mu.Unlock()
+	if len(errs) == 0 {
+		return nil
+	}
+	err := newError(initialSkip)
+	err.Wraps = errors.Join(errs...)
+	return err
+}
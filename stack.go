@@ -1,7 +1,11 @@
 package evs
 
 import (
+	"bytes"
+	"reflect"
 	"runtime"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -9,11 +13,35 @@ const (
 	maxStackDepth   = 200
 )
 
+// packagePrefix is the prefix of the function names of every frame that belongs to this package.
+var packagePrefix = reflect.TypeOf(Error{}).PkgPath() + "."
+
 // Frame defines a single frame in a stack trace.
 type Frame struct {
-	Line     int
-	File     string
-	Function string
+	Line     int    `json:"line"`
+	File     string `json:"file"`
+	Function string `json:"function"`
+}
+
+// Short returns the location of the frame as "[file:line]", using the base name of the file. This is the same
+// form the text formatter uses for %s.
+func (frame Frame) Short() string {
+	return "[" + textFormatter{}.location(frame) + "]"
+}
+
+// Long returns the frame as "function [file:line]", using the base name of the file. This is the same form
+// the text formatter uses for %v.
+func (frame Frame) Long() string {
+	return frame.Function + " " + frame.Short()
+}
+
+// fileBase returns the last element of a file path. Unlike path.Base, it also splits on backslashes, so that
+// paths recorded on Windows are trimmed as well.
+func fileBase(file string) string {
+	if i := strings.LastIndexAny(file, `/\`); i >= 0 {
+		return file[i+1:]
+	}
+	return file
 }
 
 // CurrentFrame gets the location information for the code point where this function was called from (or
@@ -31,7 +59,129 @@ func CurrentFrame(skip int) Frame {
 
 // Stack contains a stack trace made up of individual frames.
 type Stack struct {
-	Frames []Frame
+	Frames []Frame `json:"frames"`
+}
+
+// Top returns the innermost frame of the stack, which is where the stack was captured. It returns false if
+// the stack is empty.
+func (stack Stack) Top() (Frame, bool) {
+	if len(stack.Frames) == 0 {
+		return Frame{}, false
+	}
+	return stack.Frames[0], true
+}
+
+// Iter calls fn for each frame in the stack, starting with the innermost one, and stops early if fn returns
+// false. [Stack.Frames] remains available for callers that want the whole slice.
+func (stack Stack) Iter(fn func(frame Frame) bool) {
+	for _, frame := range stack.Frames {
+		if !fn(frame) {
+			return
+		}
+	}
+}
+
+// Strings returns each frame of the stack formatted the same way the text formatter writes it, starting with
+// the innermost one. With the 's' verb the short "[file:line]" form is used, and any other verb gives the long
+// "function [file:line]" form.
+func (stack Stack) Strings(verb rune) []string {
+	f := textFormatter{}
+	out := make([]string, 0, len(stack.Frames))
+	var buf bytes.Buffer
+	state := &writerState{w: &buf}
+	for _, frame := range stack.Frames {
+		buf.Reset()
+		f.formatFrame(frame, state, verb, 0)
+		out = append(out, buf.String())
+	}
+	return out
+}
+
+// Fold returns the stack as a single line in the folded format used by flamegraph.pl (e.g. "main.main;main.run;
+// main.handle 1"), counting it once. As flame graph tools expect, the function names go from the outermost frame
+// (the root of the graph) to the innermost one, which is the reverse of the order of [Stack.Frames]. Adding up the
+// counts of identical lines across many errors shows which code paths fail most often. It returns an empty
+// string if the stack has no frames.
+func (stack Stack) Fold() string {
+	if len(stack.Frames) == 0 {
+		return ""
+	}
+	sb := &strings.Builder{}
+	for i := len(stack.Frames) - 1; i >= 0; i-- {
+		sb.WriteString(stack.Frames[i].Function)
+		if i > 0 {
+			sb.WriteString(";")
+		}
+	}
+	sb.WriteString(" 1")
+	return sb.String()
+}
+
+// Pprof returns the stack in the layout that [runtime.Stack] (and tools that parse goroutine dumps, such as the
+// ones around pprof) use: for each frame, starting with the innermost one, the fully-qualified function name
+// followed by "(...)" on one line and the tab-indented "file:line" on the next. Argument values and program
+// counter offsets aren't captured, so they are left out.
+func (stack Stack) Pprof() string {
+	sb := &strings.Builder{}
+	for i, frame := range stack.Frames {
+		if i > 0 {
+			sb.WriteString("\n")
+		}
+		sb.WriteString(frame.Function + "(...)\n\t" + frame.File + ":" + strconv.Itoa(frame.Line))
+	}
+	return sb.String()
+}
+
+// ParseRuntimeStack parses a goroutine dump in the format written by [runtime.Stack] (or printed by a panic) into
+// a [Stack], so that e.g. the stack of a recovered panic can be rendered by the formatters like any other. Only
+// the first goroutine in the dump is parsed, which is the current one for [runtime.Stack]. Argument values and
+// program counter offsets are dropped, and "created by" lines become a frame of the function that started the
+// goroutine. Lines that can't be parsed are skipped.
+func ParseRuntimeStack(s []byte) Stack {
+	frames := []Frame{}
+	function := ""
+	started := false
+	for _, line := range strings.Split(string(s), "\n") {
+		switch {
+		case strings.HasPrefix(line, "goroutine "):
+			if started {
+				return Stack{Frames: frames}
+			}
+			started = true
+		case strings.TrimSpace(line) == "":
+			if len(frames) > 0 {
+				return Stack{Frames: frames}
+			}
+		case strings.HasPrefix(line, "\t"):
+			if function == "" {
+				continue
+			}
+			frames = append(frames, parseRuntimeLocation(function, strings.TrimPrefix(line, "\t")))
+			function = ""
+		case strings.HasPrefix(line, "created by "):
+			function, _, _ = strings.Cut(strings.TrimPrefix(line, "created by "), " in goroutine ")
+		default:
+			function = line
+			if strings.HasSuffix(line, ")") {
+				if i := strings.LastIndexByte(line, '('); i > 0 {
+					function = line[:i]
+				}
+			}
+		}
+	}
+	return Stack{Frames: frames}
+}
+
+// parseRuntimeLocation turns a "file:line +0x1d" line of a goroutine dump into a frame of the given function.
+func parseRuntimeLocation(function, location string) Frame {
+	location, _, _ = strings.Cut(location, " +0x")
+	frame := Frame{Function: function, File: location}
+	if i := strings.LastIndexByte(location, ':'); i >= 0 {
+		if line, err := strconv.Atoi(location[i+1:]); err == nil {
+			frame.File, frame.Line = location[:i], line
+		}
+	}
+	return frame
 }
 
 // GetStack returns the full set of frames excluding the frames within the evs package
@@ -57,6 +207,41 @@ func GetStack(skip int) Stack {
 	return Stack{Frames: frames}
 }
 
+// CommonPrefix returns the number of frames that both stacks share along their call path. Frames are compared
+// starting from the outermost frame (the last one in [Stack.Frames]) since that is where two call paths that
+// later split have their frames in common. Two frames are equal if their function, file, and line all match.
+func (stack Stack) CommonPrefix(other Stack) int {
+	count := 0
+	for count < len(stack.Frames) && count < len(other.Frames) {
+		if stack.Frames[len(stack.Frames)-1-count] != other.Frames[len(other.Frames)-1-count] {
+			break
+		}
+		count++
+	}
+	return count
+}
+
+// DivergePoint returns the first frame of a, following the call path from the outermost frame inward, that
+// is not shared with b. It returns false if there is no such frame, which happens when every frame in a is
+// also on the call path of b.
+func DivergePoint(a, b Stack) (Frame, bool) {
+	common := a.CommonPrefix(b)
+	if common == len(a.Frames) {
+		return Frame{}, false
+	}
+	return a.Frames[len(a.Frames)-1-common], true
+}
+
+// trimPackageFrames returns the stack without any leading frames that belong to this package.
+func trimPackageFrames(stack Stack) Stack {
+	for i, frame := range stack.Frames {
+		if !strings.HasPrefix(frame.Function, packagePrefix) {
+			return Stack{Frames: stack.Frames[i:]}
+		}
+	}
+	return Stack{}
+}
+
 func getCallerPCs(skip int) []uintptr {
 	skip++
 	skip++
@@ -80,3 +265,27 @@ func getCallerPCs(skip int) []uintptr {
 		}
 	}
 }
+
+// HasStack reports whether any [Error] in the chain of err has captured a stacktrace. It does not allocate.
+func HasStack(err error) bool {
+	found := false
+	walk(err, func(e *Error) bool {
+		found = len(e.Stack.Frames) > 0
+		return !found
+	})
+	return found
+}
+
+// OriginStack returns the stack of the innermost [Error] in the chain that captured one. This is usually the
+// stack that is closest to where the failure actually started. It returns false if no [Error] in the chain
+// has a stack.
+func OriginStack(err error) (Stack, bool) {
+	origin := Stack{}
+	walk(err, func(e *Error) bool {
+		if len(e.Stack.Frames) > 0 {
+			origin = e.Stack
+		}
+		return true
+	})
+	return origin, len(origin.Frames) > 0
+}
@@ -2,6 +2,7 @@ package evs
 
 import (
 	"fmt"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -34,3 +35,149 @@ func TestGetStackDeep(t *testing.T) {
 		t.Fatal("stack string did not contain expected output")
 	}
 }
+
+func TestStack_CommonPrefix(t *testing.T) {
+	main := Frame{Function: "main.main", File: "main.go", Line: 10}
+	handler := Frame{Function: "main.handle", File: "main.go", Line: 20}
+	a := Stack{Frames: []Frame{{Function: "main.read", File: "read.go", Line: 5}, handler, main}}
+	b := Stack{Frames: []Frame{{Function: "main.write", File: "write.go", Line: 7}, handler, main}}
+	if common := a.CommonPrefix(b); common != 2 {
+		t.Fatalf("expected 2 common frames but got %v", common)
+	}
+	frame, ok := DivergePoint(a, b)
+	if !ok {
+		t.Fatal("expected the stacks to diverge")
+	}
+	if frame.Function != "main.read" {
+		t.Fatalf("expected to diverge at main.read but got %v", frame.Function)
+	}
+}
+
+func TestStack_CommonPrefixSame(t *testing.T) {
+	a := Stack{Frames: []Frame{{Function: "main.main", File: "main.go", Line: 10}}}
+	if common := a.CommonPrefix(a); common != 1 {
+		t.Fatalf("expected 1 common frame but got %v", common)
+	}
+	if _, ok := DivergePoint(a, a); ok {
+		t.Fatal("expected the stacks to not diverge")
+	}
+}
+
+func TestStack_CommonPrefixLineDiffers(t *testing.T) {
+	a := Stack{Frames: []Frame{{Function: "main.main", File: "main.go", Line: 10}}}
+	b := Stack{Frames: []Frame{{Function: "main.main", File: "main.go", Line: 11}}}
+	if common := a.CommonPrefix(b); common != 0 {
+		t.Fatalf("expected 0 common frames but got %v", common)
+	}
+}
+
+func TestStack_Iter(t *testing.T) {
+	stack := doRecursion(5)
+	count := 0
+	stack.Iter(func(frame Frame) bool {
+		count++
+		return frame.Function == stack.Frames[0].Function
+	})
+	if count < 2 || count >= len(stack.Frames) {
+		t.Fatalf("expected iteration to stop early but it visited %v of %v frames", count, len(stack.Frames))
+	}
+}
+
+func TestStack_Top(t *testing.T) {
+	stack := GetStack(0)
+	top, ok := stack.Top()
+	if !ok || !strings.Contains(top.Function, "TestStack_Top") {
+		t.Fatalf("unexpected top frame: %v", top)
+	}
+	if _, ok := (Stack{}).Top(); ok {
+		t.Fatal("expected an empty stack to have no top frame")
+	}
+}
+
+func TestStack_Strings(t *testing.T) {
+	stack := Stack{Frames: []Frame{
+		{Function: "main.handle", File: "/src/app/main.go", Line: 20},
+		{Function: "main.main", File: "/src/app/main.go", Line: 10},
+	}}
+	long := stack.Strings('v')
+	expect := []string{"main.handle [main.go:20]", "main.main [main.go:10]"}
+	if strings.Join(long, "|") != strings.Join(expect, "|") {
+		t.Fatalf("expected %q but got %q", expect, long)
+	}
+	short := stack.Strings('s')
+	expect = []string{"[main.go:20]", "[main.go:10]"}
+	if strings.Join(short, "|") != strings.Join(expect, "|") {
+		t.Fatalf("expected %q but got %q", expect, short)
+	}
+}
+
+func TestStack_Fold(t *testing.T) {
+	stack := Stack{Frames: []Frame{
+		{Function: "main.handle", File: "main.go", Line: 30},
+		{Function: "main.run", File: "main.go", Line: 20},
+		{Function: "main.main", File: "main.go", Line: 10},
+	}}
+	if result := stack.Fold(); result != "main.main;main.run;main.handle 1" {
+		t.Fatalf("unexpected folded stack %q", result)
+	}
+	if result := (Stack{}).Fold(); result != "" {
+		t.Fatalf("expected an empty string but got %q", result)
+	}
+}
+
+func TestFrame_ShortLong(t *testing.T) {
+	frame := Frame{Function: "main.handle", File: "/src/app/main.go", Line: 20}
+	if result := frame.Short(); result != "[main.go:20]" {
+		t.Fatalf("unexpected short form %q", result)
+	}
+	if result := frame.Long(); result != "main.handle [main.go:20]" {
+		t.Fatalf("unexpected long form %q", result)
+	}
+	frame.File = `C:\src\app\main.go`
+	if result := frame.Short(); result != "[main.go:20]" {
+		t.Fatalf("unexpected short form for a Windows path %q", result)
+	}
+}
+
+func TestStack_Pprof(t *testing.T) {
+	stack := Stack{Frames: []Frame{
+		{Function: "github.com/acme/app.handle", File: "/src/app/main.go", Line: 20},
+		{Function: "main.main", File: "/src/app/main.go", Line: 10},
+	}}
+	expect := "github.com/acme/app.handle(...)\n\t/src/app/main.go:20\nmain.main(...)\n\t/src/app/main.go:10"
+	if result := stack.Pprof(); result != expect {
+		t.Fatalf("expected\n%v\nbut got\n%v", expect, result)
+	}
+}
+
+func TestParseRuntimeStack(t *testing.T) {
+	dump := `goroutine 7 [running]:
+main.(*Server).handle(0xc000012345, {0x4f1a20, 0xc0000a6000})
+	/src/app/server.go:42 +0x1d
+panic({0x4b2d20?, 0x5a1f30?})
+	/usr/local/go/src/runtime/panic.go:770 +0x132
+main.worker[...](...)
+	/src/app/worker.go:12
+created by main.main in goroutine 1
+	/src/app/main.go:8 +0x65
+
+goroutine 1 [chan receive]:
+main.main()
+	/src/app/main.go:9 +0x70
+`
+	expect := []Frame{
+		{Function: "main.(*Server).handle", File: "/src/app/server.go", Line: 42},
+		{Function: "panic", File: "/usr/local/go/src/runtime/panic.go", Line: 770},
+		{Function: "main.worker[...]", File: "/src/app/worker.go", Line: 12},
+		{Function: "main.main", File: "/src/app/main.go", Line: 8},
+	}
+	stack := ParseRuntimeStack([]byte(dump))
+	if fmt.Sprint(stack.Frames) != fmt.Sprint(expect) {
+		t.Fatalf("expected\n%v\nbut got\n%v", expect, stack.Frames)
+	}
+	buf := make([]byte, 4096)
+	stack = ParseRuntimeStack(buf[:runtime.Stack(buf, false)])
+	if top, ok := stack.Top(); !ok || !strings.HasSuffix(top.Function, "TestParseRuntimeStack") {
+		t.Fatalf("expected the current function at the top but got %v", stack.Frames)
+	}
+}
@@ -0,0 +1,26 @@
+//go:build !windows && !plan9
+
+package evs
+
+import (
+	"log/syslog"
+)
+
+// SyslogSeverity maps the level of err (see [LevelOf]) onto a syslog severity. [LevelFatal] becomes
+// [syslog.LOG_CRIT], and errors without a level are logged as [syslog.LOG_ERR]. The result doesn't include a
+// facility, so combine it with one (e.g. syslog.LOG_DAEMON|SyslogSeverity(err)) as needed. Since log/syslog
+// isn't available on Windows or Plan 9, neither is this function.
+func SyslogSeverity(err error) syslog.Priority {
+	switch LevelOf(err) {
+	case LevelDebug:
+		return syslog.LOG_DEBUG
+	case LevelInfo:
+		return syslog.LOG_INFO
+	case LevelWarn:
+		return syslog.LOG_WARNING
+	case LevelFatal:
+		return syslog.LOG_CRIT
+	default:
+		return syslog.LOG_ERR
+	}
+}
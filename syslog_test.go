@@ -0,0 +1,44 @@
+//go:build !windows && !plan9
+
+package evs_test
+
+import (
+	"fmt"
+	"log/syslog"
+	"strings"
+	"testing"
+
+	"github.com/thenorthnate/evs"
+)
+
+func TestSyslogSeverity(t *testing.T) {
+	cases := map[evs.Level]syslog.Priority{
+		evs.LevelUnset: syslog.LOG_ERR,
+		evs.LevelDebug: syslog.LOG_DEBUG,
+		evs.LevelInfo:  syslog.LOG_INFO,
+		evs.LevelWarn:  syslog.LOG_WARNING,
+		evs.LevelError: syslog.LOG_ERR,
+		evs.LevelFatal: syslog.LOG_CRIT,
+	}
+	for level, expect := range cases {
+		err := fmt.Errorf("outer: %w", evs.New("bad day").WithLevel(level).Err())
+		if got := evs.SyslogSeverity(err); got != expect {
+			t.Fatalf("expected %v to map to %v but got %v", level, expect, got)
+		}
+	}
+}
+
+func TestSyslogFormatter(t *testing.T) {
+	err := evs.From(fmt.Errorf("line one\nline two")).Msg("bad day").Fmt(evs.SyslogFormatter()).Err()
+	result := err.Error()
+	if strings.Contains(result, "\n") {
+		t.Fatalf("expected a single line but got %q", result)
+	}
+	if !strings.HasPrefix(result, "line one line two [bad day] At [syslog_test.go:") {
+		t.Fatalf("unexpected output %q", result)
+	}
+	spaced := evs.New("tab\there,  two spaces").DropStack().Fmt(evs.SyslogFormatter()).Err()
+	if result := spaced.Error(); result != "[tab\there,  two spaces]" {
+		t.Fatalf("expected tabs and spaces to be kept but got %q", result)
+	}
+}